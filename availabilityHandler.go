@@ -0,0 +1,136 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+)
+
+// Response Schema of /availability
+type availabilityResponse struct {
+	From time.Time         `json:"from"`
+	To   time.Time         `json:"to"`
+	Days []availabilityDay `json:"days"`
+}
+
+type availabilityDay struct {
+	Date  string `json:"date"`
+	Count int    `json:"count"`
+	// Mean cloud cover of all products with known cloud cover
+	CloudCover *float64 `json:"cloudCover"`
+}
+
+// AvailabilityHandler returns the number of products and their mean cloud cover for every day with matching products.
+// Products are filtered like in /search, days are based on the generation time in UTC.
+func AvailabilityHandler(w http.ResponseWriter, r *http.Request) {
+	defer Timetrack(time.Now(), "Availability ")
+	q := r.URL.Query()
+
+	// Log request if verbose is set
+	if Verbose {
+		fmt.Print("Request to /availability with parameters: ")
+		fmt.Println(q)
+	}
+
+	// Get date range
+	from, to, err := parseDayRange(q.Get("from"), q.Get("to"))
+	if err != nil {
+		w.WriteHeader(400)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	spatial, err := parseSpatialFilter(q)
+	if err != nil {
+		w.WriteHeader(400)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	// Get all Datasets from Index
+	datasets, err := Index.All()
+	if err != nil {
+		w.WriteHeader(500)
+		w.Write([]byte("Unable to read Index: " + err.Error()))
+		return
+	}
+
+	// Filter like /search
+	err = propertiesFilter(datasets, q)
+	if err != nil {
+		w.WriteHeader(400)
+		w.Write([]byte(err.Error()))
+		return
+	}
+	err = metaDataFilter(datasets, from.Format(time.RFC3339Nano), to.Format(time.RFC3339Nano), spatial, true)
+	if err != nil {
+		w.WriteHeader(500)
+		w.Write([]byte("Unable to filter by metadata: " + err.Error()))
+		return
+	}
+
+	// Count products per day
+	days := make(map[string]*availabilityDay)
+	cloudCovers := make(map[string][]float64)
+	for _, dataset := range datasets {
+		if dataset == nil {
+			continue
+		}
+		date := dataset.GenerationTime.UTC().Format("2006-01-02")
+		if _, ok := days[date]; !ok {
+			days[date] = &availabilityDay{Date: date}
+		}
+		days[date].Count++
+		if dataset.CloudCover != nil {
+			cloudCovers[date] = append(cloudCovers[date], *dataset.CloudCover)
+		}
+	}
+
+	response := availabilityResponse{From: from, To: to, Days: []availabilityDay{}}
+	for date, day := range days {
+		if len(cloudCovers[date]) > 0 {
+			mean := 0.0
+			for _, cloudCover := range cloudCovers[date] {
+				mean += cloudCover
+			}
+			mean /= float64(len(cloudCovers[date]))
+			day.CloudCover = &mean
+		}
+		response.Days = append(response.Days, *day)
+	}
+	sort.Slice(response.Days, func(i, j int) bool { return response.Days[i].Date < response.Days[j].Date })
+
+	writeJSON(w, "application/json", response)
+}
+
+// parseDayRange parses an optional date range. Days given without time are fully included.
+func parseDayRange(fromRAW, toRAW string) (from, to time.Time, err error) {
+	from, err = parseDay(fromRAW, time.Time{})
+	if err != nil {
+		return from, to, errors.New("Invalid parameter 'from': " + err.Error())
+	}
+	to, err = parseDay(toRAW, time.Date(9999, 12, 31, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		return from, to, errors.New("Invalid parameter 'to': " + err.Error())
+	}
+	if len(toRAW) == len("2006-01-02") {
+		to = to.Add(24*time.Hour - time.Nanosecond)
+	}
+	if to.Before(from) {
+		return from, to, errors.New("'from' is after 'to'")
+	}
+	return from, to, nil
+}
+
+// parseDay parses a date like 2018-01-05 or a RFC 3339 timestamp, returning fallback for empty strings
+func parseDay(s string, fallback time.Time) (time.Time, error) {
+	if s == "" {
+		return fallback, nil
+	}
+	if len(s) == len("2006-01-02") {
+		return time.Parse("2006-01-02", s)
+	}
+	return time.Parse(time.RFC3339, s)
+}
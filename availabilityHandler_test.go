@@ -0,0 +1,42 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseDayRange(t *testing.T) {
+	day := func(year int, month time.Month, d int) time.Time {
+		return time.Date(year, month, d, 0, 0, 0, 0, time.UTC)
+	}
+	tests := []struct {
+		from, to         string
+		wantFrom, wantTo time.Time
+	}{
+		{"", "", time.Time{}, day(9999, 12, 31)},
+		{"2018-05-01", "2018-05-31", day(2018, 5, 1), day(2018, 6, 1).Add(-time.Nanosecond)},
+		{"2018-05-01", "2018-05-01", day(2018, 5, 1), day(2018, 5, 2).Add(-time.Nanosecond)},
+		{"2018-05-01T10:00:00Z", "2018-05-01T12:00:00+02:00", day(2018, 5, 1).Add(10 * time.Hour), day(2018, 5, 1).Add(10 * time.Hour)},
+	}
+	for _, test := range tests {
+		from, to, err := parseDayRange(test.from, test.to)
+		if err != nil {
+			t.Errorf("parseDayRange(%q, %q) failed: %v", test.from, test.to, err)
+			continue
+		}
+		if !from.Equal(test.wantFrom) || !to.Equal(test.wantTo) {
+			t.Errorf("parseDayRange(%q, %q) = %v, %v, want %v, %v", test.from, test.to, from, to, test.wantFrom, test.wantTo)
+		}
+	}
+
+	for _, test := range []struct{ from, to string }{
+		{"2018-05-32", ""},
+		{"", "05/01/2018"},
+		{"2018-06-01", "2018-05-31"},
+		{"2018-05-01T10:00:00", ""},
+	} {
+		if _, _, err := parseDayRange(test.from, test.to); err == nil {
+			t.Errorf("parseDayRange(%q, %q) succeeded, want error", test.from, test.to)
+		}
+	}
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/julienschmidt/httprouter"
+	"image"
+	"image/color"
+	"image/png"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// colorStop is a color at a relative position between 0 and 1 of a colormap
+type colorStop struct {
+	Position float64
+	Color    color.RGBA
+}
+
+// colormap linearly interpolates between its stops
+type colormap []colorStop
+
+// Colormaps holds all built-in color ramps
+var Colormaps = map[string]colormap{
+	"viridis": evenStops("440154", "482878", "3e4989", "31688e", "26828e", "1f9e89", "35b779", "6ece58", "b5de2b", "fde725"),
+	"magma":   evenStops("000004", "180f3d", "440f76", "721f81", "9e2f7f", "cd4071", "f1605d", "fd9668", "feca8d", "fcfdbf"),
+	"RdYlGn":  evenStops("a50026", "d73027", "f46d43", "fdae61", "fee08b", "ffffbf", "d9ef8b", "a6d96a", "66bd63", "1a9850", "006837"),
+	"terrain": {
+		{0, hexColor("333399")},
+		{0.15, hexColor("0099ff")},
+		{0.25, hexColor("00cc66")},
+		{0.5, hexColor("ffff99")},
+		{0.75, hexColor("805c54")},
+		{1, hexColor("ffffff")},
+	},
+}
+
+// evenStops creates a colormap with evenly spaced colors
+func evenStops(colors ...string) colormap {
+	c := make(colormap, len(colors))
+	for i := range colors {
+		c[i] = colorStop{float64(i) / float64(len(colors)-1), hexColor(colors[i])}
+	}
+	return c
+}
+
+// hexColor converts a hex string of the form rrggbb to a color, panics on invalid input
+func hexColor(s string) color.RGBA {
+	c, err := parseHexColor(s)
+	if err != nil {
+		panic(err)
+	}
+	return c
+}
+
+// parseHexColor converts a hex string of the form [#]rrggbb to a color
+func parseHexColor(s string) (color.RGBA, error) {
+	b, err := hex.DecodeString(strings.TrimPrefix(s, "#"))
+	if err != nil || len(b) != 3 {
+		return color.RGBA{}, errors.New("Invalid color '" + s + "'")
+	}
+	return color.RGBA{b[0], b[1], b[2], 255}, nil
+}
+
+// parseColormap returns the built-in colormap with given name or parses a
+// user-supplied stop list of the form "0:#440154,0.5:#21918c,1:#fde725"
+func parseColormap(s string) (colormap, error) {
+	if c, ok := Colormaps[s]; ok {
+		return c, nil
+	}
+	stops := strings.Split(s, ",")
+	if len(stops) < 2 {
+		return nil, errors.New("Unknown colormap '" + s + "'")
+	}
+	c := make(colormap, len(stops))
+	for i := range stops {
+		stop := strings.SplitN(stops[i], ":", 2)
+		if len(stop) != 2 {
+			return nil, errors.New("Invalid color stop '" + stops[i] + "'")
+		}
+		position, err := strconv.ParseFloat(stop[0], 64)
+		if err != nil || position < 0 || position > 1 {
+			return nil, errors.New("Invalid position of color stop '" + stops[i] + "'")
+		}
+		c[i].Position = position
+		c[i].Color, err = parseHexColor(stop[1])
+		if err != nil {
+			return nil, err
+		}
+	}
+	sort.Slice(c, func(i, j int) bool { return c[i].Position < c[j].Position })
+	return c, nil
+}
+
+// at returns the color at relative position t
+func (c colormap) at(t float64) color.RGBA {
+	if t <= c[0].Position {
+		return c[0].Color
+	}
+	for i := 1; i < len(c); i++ {
+		if t <= c[i].Position {
+			a, b := c[i-1], c[i]
+			f := (t - a.Position) / (b.Position - a.Position)
+			mix := func(x, y uint8) uint8 { return uint8(float64(x) + f*(float64(y)-float64(x))) }
+			return color.RGBA{mix(a.Color.R, b.Color.R), mix(a.Color.G, b.Color.G), mix(a.Color.B, b.Color.B), 255}
+		}
+	}
+	return c[len(c)-1].Color
+}
+
+// palette maps 8-bit grey values to colors, keeping 0 as transparent nodata
+func (c colormap) palette() [256]color.RGBA {
+	var p [256]color.RGBA
+	for i := 1; i < 256; i++ {
+		p[i] = c.at(float64(i-1) / 254)
+	}
+	return p
+}
+
+// colormapInfo describes a colormap in the /colormaps response
+type colormapInfo struct {
+	Name    string          `json:"name"`
+	Stops   []colorStopInfo `json:"stops"`
+	Preview string          `json:"preview"`
+}
+
+type colorStopInfo struct {
+	Position float64 `json:"position"`
+	Color    string  `json:"color"`
+}
+
+// ColormapsHandler lists all built-in colormaps
+func ColormapsHandler(w http.ResponseWriter, r *http.Request) {
+	if Verbose {
+		fmt.Println("Request to /colormaps")
+	}
+
+	// Sort by name for stable output
+	var names []string
+	for name := range Colormaps {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	infos := make([]colormapInfo, len(names))
+	for i, name := range names {
+		infos[i].Name = name
+		infos[i].Preview = "/colormaps/" + name + ".png"
+		for _, stop := range Colormaps[name] {
+			infos[i].Stops = append(infos[i].Stops, colorStopInfo{
+				Position: stop.Position,
+				Color:    fmt.Sprintf("#%02x%02x%02x", stop.Color.R, stop.Color.G, stop.Color.B),
+			})
+		}
+	}
+
+	colormapsjson, err := json.Marshal(infos)
+	if err != nil {
+		w.WriteHeader(500)
+		w.Write([]byte("Unable to encode colormaps: " + err.Error()))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(colormapsjson)
+}
+
+// ColormapPreviewHandler renders a horizontal preview PNG of a colormap
+func ColormapPreviewHandler(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	name := strings.TrimSuffix(ps.ByName("name"), ".png")
+	c, ok := Colormaps[name]
+	if !ok {
+		w.WriteHeader(404)
+		w.Write([]byte("Unknown colormap '" + name + "'"))
+		return
+	}
+
+	img := image.NewRGBA(image.Rect(0, 0, 256, 16))
+	for x := 0; x < 256; x++ {
+		col := c.at(float64(x) / 255)
+		for y := 0; y < 16; y++ {
+			img.SetRGBA(x, y, col)
+		}
+	}
+	w.Header().Set("Content-Type", "image/png")
+	png.Encode(w, img)
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"image/color"
+	"testing"
+)
+
+var (
+	black = color.RGBA{0, 0, 0, 255}
+	white = color.RGBA{255, 255, 255, 255}
+	grey  = color.RGBA{127, 127, 127, 255}
+)
+
+func TestBuiltinColormaps(t *testing.T) {
+	for name := range Colormaps {
+		c, err := parseColormap(name)
+		if err != nil {
+			t.Errorf("parseColormap(%q) failed: %v", name, err)
+			continue
+		}
+		if c[0].Position != 0 || c[len(c)-1].Position != 1 {
+			t.Errorf("colormap %s spans %v to %v, want 0 to 1", name, c[0].Position, c[len(c)-1].Position)
+		}
+		for i := 1; i < len(c); i++ {
+			if c[i].Position <= c[i-1].Position {
+				t.Errorf("colormap %s has unordered stops at %v", name, c[i].Position)
+			}
+		}
+		p := c.palette()
+		if p[0] != (color.RGBA{}) || p[1] != c[0].Color || p[255] != c[len(c)-1].Color {
+			t.Errorf("palette of %s = %v, %v, %v, want nodata, first and last stop", name, p[0], p[1], p[255])
+		}
+	}
+}
+
+func TestParseColormap(t *testing.T) {
+	tests := []struct {
+		s    string
+		want colormap
+	}{
+		{"0:#000000,1:#ffffff", colormap{{0, black}, {1, white}}},
+		{"1:ffffff,0:000000", colormap{{0, black}, {1, white}}},
+		{"0.5:#7f7f7f,0:#000000,1:#FFFFFF", colormap{{0, black}, {0.5, grey}, {1, white}}},
+	}
+	for _, test := range tests {
+		got, err := parseColormap(test.s)
+		if err != nil {
+			t.Errorf("parseColormap(%q) failed: %v", test.s, err)
+			continue
+		}
+		if len(got) != len(test.want) {
+			t.Errorf("parseColormap(%q) = %v, want %v", test.s, got, test.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != test.want[i] {
+				t.Errorf("parseColormap(%q) = %v, want %v", test.s, got, test.want)
+				break
+			}
+		}
+	}
+}
+
+func TestParseColormapErrors(t *testing.T) {
+	for _, s := range []string{
+		"",
+		"jet",
+		"0:#000000",
+		"0:#000000,1.5:#ffffff",
+		"-0.1:#000000,1:#ffffff",
+		"x:#000000,1:#ffffff",
+		"0#000000,1:#ffffff",
+		"0:#00000g,1:#ffffff",
+		"0:#000,1:#ffffff",
+		"0:#00000000,1:#ffffff",
+	} {
+		if _, err := parseColormap(s); err == nil {
+			t.Errorf("parseColormap(%q) succeeded, want error", s)
+		}
+	}
+}
+
+func TestColormapAt(t *testing.T) {
+	c := colormap{{0.25, black}, {0.75, white}}
+	tests := []struct {
+		t    float64
+		want color.RGBA
+	}{
+		{-1, black},
+		{0, black},
+		{0.25, black},
+		{0.5, grey},
+		{0.75, white},
+		{1, white},
+		{2, white},
+	}
+	for _, test := range tests {
+		if got := c.at(test.t); got != test.want {
+			t.Errorf("at(%v) = %v, want %v", test.t, got, test.want)
+		}
+	}
+
+	p := colormap{{0, black}, {1, white}}.palette()
+	if p[1] != black || p[128] != grey || p[255] != white {
+		t.Errorf("palette = %v, %v, %v, want black, grey and white", p[1], p[128], p[255])
+	}
+}
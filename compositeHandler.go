@@ -0,0 +1,396 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"github.com/gorilla/schema"
+	"github.com/segmentio/ksuid"
+	"image"
+	"image/color"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Composite modes selecting the value of each pixel from all clear observations
+const (
+	CompositeMedian  = "median"
+	CompositeMaxNDVI = "maxndvi"
+	CompositeLatest  = "latest"
+)
+
+// Scene classes masked by default, additionally to clouds and their shadows
+const compositeDefaultMask = "nodata,saturated"
+
+// Default upper display bound, DN of a reflectance of 0.3 which suits most land surfaces
+const compositeDefaultMax = 3000
+
+// Maximum number of products combined into one composite
+const compositeMaxProducts = 100
+
+// HTTP-POST Body options of /composite
+type compositeOptions struct {
+	Bbox     string  `schema:"bbox"`
+	From     string  `schema:"from"`
+	To       string  `schema:"to"`
+	Bands    string  `schema:"bands"`
+	Mode     string  `schema:"mode"`
+	Mask     string  `schema:"mask"`
+	MaxCloud string  `schema:"maxcloud"`
+	Min      float64 `schema:"min"`
+	Max      float64 `schema:"max"`
+}
+
+// Result of /composite jobs
+type compositeResult struct {
+	Products []string      `json:"products"`
+	Limits   stretchLimits `json:"limits"`
+}
+
+// CompositeHandler queues a job compositing all L2A products intersecting a bbox within a date range into one cloud-free image.
+// The result is tiled into data/{id}/ like the output of GenerateHandler.
+func CompositeHandler(w http.ResponseWriter, r *http.Request) {
+	defer Timetrack(time.Now(), "CompositeHandler ")
+
+	err := r.ParseForm()
+	var options compositeOptions
+	if err == nil {
+		err = schema.NewDecoder().Decode(&options, r.PostForm)
+	}
+
+	// Log request if verbose is set
+	if Verbose {
+		fmt.Print("Request to /composite with following parameters: ")
+		fmt.Println(options)
+	}
+
+	if err != nil {
+		w.WriteHeader(400)
+		w.Write([]byte("Unable to parse parameters: " + err.Error()))
+		return
+	}
+	if options.Bbox == "" || options.Bands == "" {
+		w.WriteHeader(400)
+		w.Write([]byte("Parameters 'bbox' and 'bands' are required"))
+		return
+	}
+	bbox, err := parseBbox(options.Bbox)
+	if err != nil {
+		w.WriteHeader(400)
+		w.Write([]byte("Unable to parse bbox: " + err.Error()))
+		return
+	}
+	from, to, err := parseDayRange(options.From, options.To)
+	if err != nil {
+		w.WriteHeader(400)
+		w.Write([]byte(err.Error()))
+		return
+	}
+	bands := strings.Split(options.Bands, ",")
+	for i := range bands {
+		bands[i] = normalizeBandname(strings.TrimSpace(bands[i]))
+		if !bandPattern.MatchString(bands[i]) {
+			w.WriteHeader(400)
+			w.Write([]byte("Unknown spectral band '" + bands[i] + "'"))
+			return
+		}
+	}
+	if len(bands) != 1 && len(bands) != 3 {
+		w.WriteHeader(400)
+		w.Write([]byte("Composites need either one band or three bands for red, green and blue"))
+		return
+	}
+	switch options.Mode {
+	case "":
+		options.Mode = CompositeMedian
+	case CompositeMedian, CompositeMaxNDVI, CompositeLatest:
+	default:
+		w.WriteHeader(400)
+		w.Write([]byte("Unknown composite mode '" + options.Mode + "', use " + CompositeMedian + ", " + CompositeMaxNDVI + " or " + CompositeLatest))
+		return
+	}
+	if options.Mask == "" {
+		options.Mask = compositeDefaultMask + "," + strings.Join(cloudySceneClasses, ",")
+	}
+	classes, err := parseMaskClasses(options.Mask)
+	if err != nil {
+		w.WriteHeader(400)
+		w.Write([]byte("Unable to parse mask: " + err.Error()))
+		return
+	}
+	if options.Min == 0 && options.Max == 0 {
+		options.Max = compositeDefaultMax
+	}
+	if options.Min >= options.Max {
+		w.WriteHeader(400)
+		w.Write([]byte("Parameter 'min' must be less than 'max'"))
+		return
+	}
+
+	// Clouds are masked by the scene classification only available in L2A products
+	datasets, err := searchProducts(url.Values{
+		"bbox":     {options.Bbox},
+		"level":    {"L2A"},
+		"maxcloud": {options.MaxCloud},
+	})
+	if err != nil {
+		w.WriteHeader(400)
+		w.Write([]byte("Unable to select products: " + err.Error()))
+		return
+	}
+
+	// Select products sensed within the date range, products may have been generated much later
+	sensingTimeFilter(datasets, from, to)
+	var products []*Product
+	for _, dataset := range datasets {
+		if dataset != nil {
+			products = append(products, dataset)
+		}
+	}
+	if len(products) == 0 {
+		w.WriteHeader(400)
+		w.Write([]byte("No L2A products intersect the bbox within the date range"))
+		return
+	}
+	if len(products) > compositeMaxProducts {
+		w.WriteHeader(400)
+		w.Write([]byte("Composites can combine at most " + strconv.Itoa(compositeMaxProducts) + " products, got " + strconv.Itoa(len(products))))
+		return
+	}
+	_, err = sortProducts(products, "date", "desc")
+	if err != nil {
+		w.WriteHeader(500)
+		w.Write([]byte("Unable to sort products: " + err.Error()))
+		return
+	}
+
+	// Generate composite in background
+	id, err := ksuid.NewRandom()
+	if err != nil {
+		w.WriteHeader(500)
+		w.Write([]byte("Unable to create job id: " + err.Error()))
+		return
+	}
+	_, err = Jobs.Enqueue(id.String(), func(job *Job) error {
+		return generateComposite(id.String(), products, bbox, bands, classes, options, job)
+	})
+	if err != nil {
+		w.WriteHeader(503)
+		w.Write([]byte("Unable to queue composite: " + err.Error()))
+		return
+	}
+
+	// 202 Response with job id
+	w.WriteHeader(202)
+	w.Write([]byte(id.String()))
+}
+
+// generateComposite composites products, ordered latest first, and tiles the result into data/{id}/
+func generateComposite(id string, products []*Product, bbox [4]float64, bands []string, classes []int, options compositeOptions, job *Job) error {
+	limits := make([][2]float64, len(bands))
+	for i := range limits {
+		limits[i] = [2]float64{options.Min, options.Max}
+	}
+	c := &compositeRenderer{
+		mode:     options.Mode,
+		channels: len(bands),
+		bbox:     bbox,
+		colorize: stretchColors(limits),
+	}
+	for _, class := range classes {
+		c.masked[class] = true
+	}
+	defer c.Close()
+
+	// Every product contributes the requested bands, B08 and B04 for NDVI and its scene classification
+	layers := append([]string(nil), bands...)
+	if c.mode == CompositeMaxNDVI {
+		layers = append(layers, "B08", "B04")
+	}
+	layers = append(layers, "SCL")
+	result := compositeResult{Products: []string{}, Limits: stretchLimits{options.Min, options.Max}}
+	for _, p := range products {
+		files := make([]string, len(layers))
+		var err error
+		for i, band := range layers {
+			files[i], err = bandLocation(p.Name, band)
+			if err != nil {
+				break
+			}
+		}
+		if err != nil {
+			if Verbose {
+				fmt.Println("Skipping " + p.Name + " in composite: " + err.Error())
+			}
+			continue
+		}
+		renderer, err := newRasterRenderer(files, firstBands(len(files)), nil)
+		if err != nil {
+			return errors.New("Unable to open Dataset " + p.Name + ": " + err.Error())
+		}
+		c.renderers = append(c.renderers, renderer)
+		result.Products = append(result.Products, p.Name)
+	}
+	if len(c.renderers) == 0 {
+		return errors.New("None of the products contains all bands")
+	}
+	Jobs.SetResult(job, result)
+
+	if Verbose {
+		fmt.Println("Tiling composite of " + strconv.Itoa(len(c.renderers)) + " products...")
+	}
+	err := writePyramid(c, "data/"+id, MinZoom, MaxZoom, func(percent int) {
+		Jobs.SetProgress(job, percent)
+	})
+	if err != nil {
+		return errors.New("Unable to tile composite: " + err.Error())
+	}
+	return nil
+}
+
+// compositeRenderer renders tiles choosing the value of each pixel from all clear observations of several products.
+// Each renderer samples the output bands, B08 and B04 in maxndvi mode and the SCL band last.
+type compositeRenderer struct {
+	renderers []*rasterRenderer
+	mode      string
+	channels  int
+	masked    [sceneClassCount]bool
+	bbox      [4]float64
+	colorize  func(values []uint16) color.RGBA
+}
+
+// Close closes all renderers of the composite
+func (c *compositeRenderer) Close() {
+	for _, r := range c.renderers {
+		r.Close()
+	}
+}
+
+func (c *compositeRenderer) bounds() (west, south, east, north float64) {
+	return c.bbox[0], c.bbox[1], c.bbox[2], c.bbox[3]
+}
+
+func (c *compositeRenderer) renderTile(z, x, y int) (*image.RGBA, error) {
+	// Sample all products overlapping the tile
+	var samples [][]uint16
+	var layers []int
+	for _, r := range c.renderers {
+		west, south, east, north := r.bounds()
+		minx, miny, maxx, maxy := tileRange(z, west, south, east, north)
+		if x < minx || x > maxx || y < miny || y > maxy {
+			continue
+		}
+		values, err := r.sampleTile(z, x, y)
+		if err != nil {
+			return nil, err
+		}
+		if values != nil {
+			samples = append(samples, values)
+			layers = append(layers, len(r.layers))
+		}
+	}
+	if len(samples) == 0 {
+		return nil, nil
+	}
+
+	// Composite pixels inside of the bbox
+	lon, lat := tilePoints(z, x, y)
+	img := image.NewRGBA(image.Rect(0, 0, tileSize, tileSize))
+	pixel := make([]uint16, c.channels)
+	stacks := make([][]uint16, c.channels)
+	empty := true
+	for i := range lon {
+		if lon[i] < c.bbox[0] || lon[i] > c.bbox[2] || lat[i] < c.bbox[1] || lat[i] > c.bbox[3] {
+			continue
+		}
+		if !c.composite(samples, layers, i, pixel, stacks) {
+			continue
+		}
+		col := c.colorize(pixel)
+		if col.A != 0 {
+			empty = false
+		}
+		img.SetRGBA(i%tileSize, i/tileSize, col)
+	}
+	if empty {
+		return nil, nil
+	}
+	return img, nil
+}
+
+// composite selects the output values of tile pixel i from all samples. Returns false if no observation is clear.
+func (c *compositeRenderer) composite(samples [][]uint16, layers []int, i int, pixel []uint16, stacks [][]uint16) bool {
+	for ch := range stacks {
+		stacks[ch] = stacks[ch][:0]
+	}
+	found := false
+	best := math.Inf(-1)
+	for s, values := range samples {
+		p := values[i*layers[s] : (i+1)*layers[s]]
+		if !c.clear(p) {
+			continue
+		}
+		switch c.mode {
+		case CompositeLatest:
+			// Samples are ordered latest first
+			copy(pixel, p[:c.channels])
+			return true
+		case CompositeMaxNDVI:
+			nir, red := float64(p[c.channels]), float64(p[c.channels+1])
+			ndvi := (nir - red) / (nir + red)
+			if ndvi > best {
+				best = ndvi
+				copy(pixel, p[:c.channels])
+				found = true
+			}
+		default:
+			for ch := range stacks {
+				stacks[ch] = append(stacks[ch], p[ch])
+			}
+			found = true
+		}
+	}
+	if !found || c.mode != CompositeMedian {
+		return found
+	}
+
+	// Median of every channel, stacks are short so insertion sort avoids allocations
+	for ch, stack := range stacks {
+		insertionSort(stack)
+		n := len(stack)
+		pixel[ch] = stack[n/2]
+		if n%2 == 0 {
+			pixel[ch] = uint16((uint32(stack[n/2-1]) + uint32(stack[n/2])) / 2)
+		}
+	}
+	return true
+}
+
+// insertionSort sorts values in place in ascending order
+func insertionSort(values []uint16) {
+	for i := 1; i < len(values); i++ {
+		v := values[i]
+		j := i
+		for ; j > 0 && values[j-1] > v; j-- {
+			values[j] = values[j-1]
+		}
+		values[j] = v
+	}
+}
+
+// clear reports whether the observation p holds data in all layers and its scene class is not masked
+func (c *compositeRenderer) clear(p []uint16) bool {
+	class := p[len(p)-1]
+	if class == math.MaxUint16 || int(class) >= sceneClassCount || c.masked[class] {
+		return false
+	}
+	for _, v := range p[:len(p)-1] {
+		if v == 0 || v == math.MaxUint16 {
+			return false
+		}
+	}
+	return true
+}
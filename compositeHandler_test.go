@@ -0,0 +1,132 @@
+package main
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+// compositeTestRenderer returns a renderer of mode masking clouds (8, 9) and cirrus (10)
+func compositeTestRenderer(mode string, channels int) *compositeRenderer {
+	c := &compositeRenderer{mode: mode, channels: channels}
+	for _, class := range []int{8, 9, 10} {
+		c.masked[class] = true
+	}
+	return c
+}
+
+func TestClear(t *testing.T) {
+	c := compositeTestRenderer(CompositeMedian, 2)
+	tests := []struct {
+		p    []uint16
+		want bool
+	}{
+		{[]uint16{500, 600, 4}, true},
+		{[]uint16{500, 600, 9}, false},
+		{[]uint16{500, 0, 4}, false},
+		{[]uint16{math.MaxUint16, 600, 4}, false},
+		{[]uint16{500, 600, math.MaxUint16}, false},
+		{[]uint16{500, 600, sceneClassCount}, false},
+	}
+	for _, test := range tests {
+		if got := c.clear(test.p); got != test.want {
+			t.Errorf("clear(%v) = %v, want %v", test.p, got, test.want)
+		}
+	}
+}
+
+func TestComposite(t *testing.T) {
+	tests := []struct {
+		name     string
+		mode     string
+		channels int
+		// one observation of a single pixel per sample, latest first
+		samples [][]uint16
+		want    []uint16
+		found   bool
+	}{
+		{"median odd", CompositeMedian, 2, [][]uint16{
+			{300, 900, 4},
+			{100, 700, 4},
+			{200, 800, 5},
+		}, []uint16{200, 800}, true},
+		{"median even", CompositeMedian, 1, [][]uint16{
+			{400, 4},
+			{100, 4},
+			{5000, 9},
+			{200, 4},
+			{300, 4},
+		}, []uint16{250}, true},
+		{"median all cloudy", CompositeMedian, 1, [][]uint16{
+			{400, 9},
+			{100, 8},
+		}, nil, false},
+		{"latest skips clouds", CompositeLatest, 2, [][]uint16{
+			{900, 900, 9},
+			{0, 300, 4},
+			{100, 200, 4},
+			{300, 400, 4},
+		}, []uint16{100, 200}, true},
+		{"maxndvi", CompositeMaxNDVI, 1, [][]uint16{
+			{100, 3000, 1000, 4},
+			{200, 4000, 500, 4},
+			{300, 5000, 100, 10},
+			{400, 2000, 2000, 5},
+		}, []uint16{200}, true},
+	}
+	for _, test := range tests {
+		c := compositeTestRenderer(test.mode, test.channels)
+		layers := make([]int, len(test.samples))
+		for s := range test.samples {
+			layers[s] = len(test.samples[s])
+		}
+		pixel := make([]uint16, test.channels)
+		stacks := make([][]uint16, test.channels)
+		found := c.composite(test.samples, layers, 0, pixel, stacks)
+		if found != test.found {
+			t.Errorf("%s: composite found = %v, want %v", test.name, found, test.found)
+			continue
+		}
+		if found && !reflect.DeepEqual(pixel, test.want) {
+			t.Errorf("%s: composite = %v, want %v", test.name, pixel, test.want)
+		}
+	}
+}
+
+func TestCompositePixelOffset(t *testing.T) {
+	c := compositeTestRenderer(CompositeLatest, 1)
+
+	// Two pixels per sample, the second one is cloudy in the latest sample
+	samples := [][]uint16{
+		{100, 4, 200, 9},
+		{300, 4, 400, 4},
+	}
+	pixel := make([]uint16, 1)
+	stacks := make([][]uint16, 1)
+	if !c.composite(samples, []int{2, 2}, 1, pixel, stacks) || pixel[0] != 400 {
+		t.Errorf("composite of second pixel = %v, want [400]", pixel)
+	}
+}
+
+func TestInsertionSort(t *testing.T) {
+	for _, values := range [][]uint16{
+		{},
+		{1},
+		{3, 1, 2},
+		{5, 5, 1, 9, 0, math.MaxUint16, 2},
+	} {
+		want := make([]uint16, len(values))
+		copy(want, values)
+		for i := range want {
+			for j := i + 1; j < len(want); j++ {
+				if want[j] < want[i] {
+					want[i], want[j] = want[j], want[i]
+				}
+			}
+		}
+		insertionSort(values)
+		if !reflect.DeepEqual(values, want) {
+			t.Errorf("insertionSort = %v, want %v", values, want)
+		}
+	}
+}
@@ -0,0 +1,230 @@
+package main
+
+import (
+	"errors"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// SpectralIndices maps names of built-in indices to their band math expression
+var SpectralIndices = map[string]string{
+	"NDVI": "(B08-B04)/(B08+B04)",
+	"NDWI": "(B03-B08)/(B03+B08)",
+	"NDSI": "(B03-B11)/(B03+B11)",
+	"NBR":  "(B08-B12)/(B08+B12)",
+	"EVI":  "2.5*(B08-B04)/(B08+6*B04-7.5*B02+1)",
+	"SAVI": "1.5*(B08-B04)/(B08+B04+0.5)",
+}
+
+// Sentinel-2 digital numbers are reflectance scaled by this value
+const quantificationValue = 10000.0
+
+// Valid Sentinel-2 band names
+var bandPattern = regexp.MustCompile(`^B(0[1-9]|1[0-2]|8A)$`)
+
+// expression is a parsed band math expression
+type expression struct {
+	root node
+	// Bands referenced by the expression, eval expects values in this order
+	bands []string
+}
+
+// node of the expression syntax tree
+type node interface {
+	eval(values []float64) float64
+}
+
+type number float64
+
+func (n number) eval(values []float64) float64 { return float64(n) }
+
+type bandRef int
+
+func (b bandRef) eval(values []float64) float64 { return values[b] }
+
+type negation struct{ operand node }
+
+func (n negation) eval(values []float64) float64 { return -n.operand.eval(values) }
+
+type operation struct {
+	operator    byte
+	left, right node
+}
+
+func (o operation) eval(values []float64) float64 {
+	l := o.left.eval(values)
+	r := o.right.eval(values)
+	switch o.operator {
+	case '+':
+		return l + r
+	case '-':
+		return l - r
+	case '*':
+		return l * r
+	default:
+		return l / r
+	}
+}
+
+// parseExpression parses band math like "(B08-B04)/(B08+B04)" or the name of a built-in index
+func parseExpression(expr string) (*expression, error) {
+	if builtin, ok := SpectralIndices[strings.ToUpper(strings.TrimSpace(expr))]; ok {
+		expr = builtin
+	}
+	p := &parser{input: expr, e: &expression{}}
+	p.next()
+	root, err := p.parseSum()
+	if err != nil {
+		return nil, err
+	}
+	if p.token != "" {
+		return nil, errors.New("Unexpected '" + p.token + "' in expression")
+	}
+	if len(p.e.bands) == 0 {
+		return nil, errors.New("Expression does not reference any band")
+	}
+	p.e.root = root
+	return p.e, nil
+}
+
+// eval evaluates the expression for one pixel given reflectance values of all referenced bands
+func (e *expression) eval(values []float64) float64 {
+	return e.root.eval(values)
+}
+
+// evalDN evaluates the expression for one pixel given digital numbers of all referenced bands.
+// Returns NaN if any band holds nodata or the result is not finite.
+func (e *expression) evalDN(dn []uint16, values []float64) float64 {
+	for i := range dn {
+		if dn[i] == 0 {
+			return math.NaN()
+		}
+		values[i] = float64(dn[i]) / quantificationValue
+	}
+	v := e.root.eval(values)
+	if math.IsInf(v, 0) {
+		return math.NaN()
+	}
+	return v
+}
+
+// parser is a recursive descent parser for band math expressions
+type parser struct {
+	input string
+	pos   int
+	token string
+	e     *expression
+}
+
+// next advances to the next token
+func (p *parser) next() {
+	for p.pos < len(p.input) && p.input[p.pos] == ' ' {
+		p.pos++
+	}
+	if p.pos >= len(p.input) {
+		p.token = ""
+		return
+	}
+	start := p.pos
+	c := rune(p.input[p.pos])
+	switch {
+	case unicode.IsLetter(c):
+		for p.pos < len(p.input) && (unicode.IsLetter(rune(p.input[p.pos])) || unicode.IsDigit(rune(p.input[p.pos]))) {
+			p.pos++
+		}
+	case unicode.IsDigit(c) || c == '.':
+		for p.pos < len(p.input) && (unicode.IsDigit(rune(p.input[p.pos])) || p.input[p.pos] == '.') {
+			p.pos++
+		}
+	default:
+		p.pos++
+	}
+	p.token = p.input[start:p.pos]
+}
+
+// parseSum parses term {('+'|'-') term}
+func (p *parser) parseSum() (node, error) {
+	left, err := p.parseProduct()
+	if err != nil {
+		return nil, err
+	}
+	for p.token == "+" || p.token == "-" {
+		operator := p.token[0]
+		p.next()
+		right, err := p.parseProduct()
+		if err != nil {
+			return nil, err
+		}
+		left = operation{operator, left, right}
+	}
+	return left, nil
+}
+
+// parseProduct parses factor {('*'|'/') factor}
+func (p *parser) parseProduct() (node, error) {
+	left, err := p.parseFactor()
+	if err != nil {
+		return nil, err
+	}
+	for p.token == "*" || p.token == "/" {
+		operator := p.token[0]
+		p.next()
+		right, err := p.parseFactor()
+		if err != nil {
+			return nil, err
+		}
+		left = operation{operator, left, right}
+	}
+	return left, nil
+}
+
+// parseFactor parses numbers, bands, negations and parenthesized expressions
+func (p *parser) parseFactor() (node, error) {
+	token := p.token
+	switch {
+	case token == "":
+		return nil, errors.New("Unexpected end of expression")
+	case token == "-":
+		p.next()
+		operand, err := p.parseFactor()
+		if err != nil {
+			return nil, err
+		}
+		return negation{operand}, nil
+	case token == "(":
+		p.next()
+		inner, err := p.parseSum()
+		if err != nil {
+			return nil, err
+		}
+		if p.token != ")" {
+			return nil, errors.New("Missing ')' in expression")
+		}
+		p.next()
+		return inner, nil
+	case unicode.IsDigit(rune(token[0])) || token[0] == '.':
+		value, err := strconv.ParseFloat(token, 64)
+		if err != nil {
+			return nil, errors.New("Invalid number '" + token + "' in expression")
+		}
+		p.next()
+		return number(value), nil
+	case unicode.IsLetter(rune(token[0])):
+		band := normalizeBandname(token)
+		if !bandPattern.MatchString(band) {
+			return nil, errors.New("Unknown band '" + token + "' in expression")
+		}
+		p.next()
+		for i := range p.e.bands {
+			if p.e.bands[i] == band {
+				return bandRef(i), nil
+			}
+		}
+		p.e.bands = append(p.e.bands, band)
+		return bandRef(len(p.e.bands) - 1), nil
+	}
+	return nil, errors.New("Unexpected '" + token + "' in expression")
+}
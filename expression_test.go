@@ -0,0 +1,89 @@
+package main
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestParseExpression(t *testing.T) {
+	tests := []struct {
+		expr   string
+		bands  []string
+		values []float64
+		want   float64
+	}{
+		{"B04", []string{"B04"}, []float64{0.25}, 0.25},
+		{"b4", []string{"B04"}, []float64{0.25}, 0.25},
+		{"NDVI", []string{"B08", "B04"}, []float64{0.5, 0.1}, 0.4 / 0.6},
+		{" ndvi ", []string{"B08", "B04"}, []float64{0.5, 0.1}, 0.4 / 0.6},
+		{"(B08-B04)/(B08+B04)", []string{"B08", "B04"}, []float64{0.3, 0.1}, 0.5},
+		{"B02+B03*2", []string{"B02", "B03"}, []float64{1, 2}, 5},
+		{"(B02+B03)*2", []string{"B02", "B03"}, []float64{1, 2}, 6},
+		{"B8A-B02-B03", []string{"B8A", "B02", "B03"}, []float64{5, 2, 1}, 2},
+		{"-B02*-.5", []string{"B02"}, []float64{4}, 2},
+		{"B02/B02 + B02", []string{"B02"}, []float64{2}, 3},
+	}
+	for _, test := range tests {
+		e, err := parseExpression(test.expr)
+		if err != nil {
+			t.Errorf("parseExpression(%q) failed: %v", test.expr, err)
+			continue
+		}
+		if !reflect.DeepEqual(e.bands, test.bands) {
+			t.Errorf("parseExpression(%q).bands = %v, want %v", test.expr, e.bands, test.bands)
+		}
+		if got := e.eval(test.values); math.Abs(got-test.want) > 1e-9 {
+			t.Errorf("parseExpression(%q).eval(%v) = %v, want %v", test.expr, test.values, got, test.want)
+		}
+	}
+}
+
+func TestParseExpressionErrors(t *testing.T) {
+	for _, expr := range []string{
+		"",
+		"2*3",
+		"B13",
+		"B04+",
+		"(B04",
+		"B04)",
+		"B04 B08",
+		"1..2*B04",
+		"B04%2",
+	} {
+		if _, err := parseExpression(expr); err == nil {
+			t.Errorf("parseExpression(%q) succeeded, want error", expr)
+		}
+	}
+}
+
+func TestEvalDN(t *testing.T) {
+	e, err := parseExpression("NDVI")
+	if err != nil {
+		t.Fatal(err)
+	}
+	values := make([]float64, 2)
+	tests := []struct {
+		dn   []uint16
+		want float64
+	}{
+		{[]uint16{3000, 1000}, 0.5},
+		{[]uint16{0, 1000}, math.NaN()},
+		{[]uint16{1000, 0}, math.NaN()},
+	}
+	for _, test := range tests {
+		got := e.evalDN(test.dn, values)
+		if math.IsNaN(test.want) != math.IsNaN(got) || (!math.IsNaN(got) && math.Abs(got-test.want) > 1e-9) {
+			t.Errorf("evalDN(%v) = %v, want %v", test.dn, got, test.want)
+		}
+	}
+
+	// Division by zero is nodata
+	e, err = parseExpression("B04/(B04-B04)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := e.evalDN([]uint16{1000}, values); !math.IsNaN(got) {
+		t.Errorf("evalDN of division by zero = %v, want NaN", got)
+	}
+}
@@ -9,7 +9,7 @@ import (
 	"io/ioutil"
 	"math"
 	"net/http"
-	"os/exec"
+	"os"
 	"strings"
 	"time"
 )
@@ -28,6 +28,11 @@ type options struct {
 	Rcn     string  `schema:"rcn"`
 	Gcn     string  `schema:"gcn"`
 	Bcn     string  `schema:"bcn"`
+	Expr    string  `schema:"expr"`
+	Cmap    string  `schema:"colormap"`
+	Stretch string  `schema:"stretch"`
+	Bbox    string  `schema:"bbox"`
+	Mask    string  `schema:"mask"`
 	Greymin float64 `schema:"greymin"`
 	Rcmin   float64 `schema:"rcmin"`
 	Gcmin   float64 `schema:"gcmin"`
@@ -36,6 +41,12 @@ type options struct {
 	Rcmax   float64 `schema:"rcmax"`
 	Gcmax   float64 `schema:"gcmax"`
 	Bcmax   float64 `schema:"bcmax"`
+
+	// Mosaics combine a list of products or all results of a /search query.
+	// All products share one stretch, so histogram equalization is not supported. Masked mosaics only combine L2A products.
+	Products string `schema:"products"`
+	Search   string `schema:"search"`
+	Order    string `schema:"order"`
 }
 
 // GenerateHandler handles all Requests for Dataset Generation
@@ -63,8 +74,81 @@ func GenerateHandler(w http.ResponseWriter, r *http.Request) {
 	// Get Name of original Dataset for later georeferencing
 	var originalDataset string
 
+	// Validate colormap before queueing
+	_, err = optionalColormap(options.Cmap)
+	if err != nil {
+		w.WriteHeader(400)
+		w.Write([]byte("Unable to parse colormap: " + err.Error()))
+		return
+	}
+
+	// Validate contrast stretch before queueing
+	if options.Stretch != "" {
+		err = validStretch(options.Stretch)
+		if err == nil && options.Bbox != "" {
+			_, err = parseBbox(options.Bbox)
+		}
+		if err != nil {
+			w.WriteHeader(400)
+			w.Write([]byte("Unable to parse stretch: " + err.Error()))
+			return
+		}
+	}
+
+	// Validate masked scene classes before queueing
+	if options.Mask != "" {
+		_, err = parseMaskClasses(options.Mask)
+		if err != nil {
+			w.WriteHeader(400)
+			w.Write([]byte("Unable to parse mask: " + err.Error()))
+			return
+		}
+	}
+
+	// Mosaics are rendered from the source Datasets of all products
+	if options.Products != "" || options.Search != "" {
+		if options.Stretch == StretchHistEq {
+			w.WriteHeader(400)
+			w.Write([]byte("Histogram equalization is not supported for mosaics, use another stretch"))
+			return
+		}
+		if options.Expr != "" {
+			_, err = parseExpression(options.Expr)
+			if err != nil {
+				w.WriteHeader(400)
+				w.Write([]byte("Unable to parse expression: " + err.Error()))
+				return
+			}
+		}
+		products, err := mosaicProducts(options)
+		if err != nil {
+			w.WriteHeader(400)
+			w.Write([]byte("Unable to select products: " + err.Error()))
+			return
+		}
+		_, err = Jobs.Enqueue(options.id, func(job *Job) error {
+			return generateMosaic(products, options, job)
+		})
+		if err != nil {
+			w.WriteHeader(503)
+			w.Write([]byte("Unable to queue generation: " + err.Error()))
+			return
+		}
+		w.WriteHeader(202)
+		w.Write([]byte(options.id))
+		return
+	}
+
 	// Get Name of original Dataset
-	if options.S2A {
+	if options.Expr != "" {
+		// Validate band math, georeference is taken from its highest resolution band
+		_, err = parseExpression(options.Expr)
+		if err != nil {
+			w.WriteHeader(400)
+			w.Write([]byte("Unable to parse expression: " + err.Error()))
+			return
+		}
+	} else if options.S2A {
 		var resolution string
 		var datasetname string
 
@@ -88,6 +172,7 @@ func GenerateHandler(w http.ResponseWriter, r *http.Request) {
 				fmt.Println("Error finding the Dataset")
 				fmt.Println(err.Error())
 			}
+			return
 		}
 
 		// get name of Dataset to copy georeference
@@ -105,81 +190,135 @@ func GenerateHandler(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	// Read Data from source datasets
-	if options.Rgbbool {
-		err = HandleRGB(originalDataset, options, w)
-	} else if options.TCI {
-		err = HandleTCI(originalDataset, options, w)
-	} else {
-		err = HandleGSC(originalDataset, options, w)
-	}
+	// Generate Dataset in background, options.id doubles as job id
+	_, err = Jobs.Enqueue(options.id, func(job *Job) error {
+		return generate(originalDataset, options, job)
+	})
 	if err != nil {
+		w.WriteHeader(503)
+		w.Write([]byte("Unable to queue generation: " + err.Error()))
 		return
 	}
 
-	// choose correct NODATA values
-	var nodata string
-	if options.Rgbbool {
-		nodata = "0,0,0"
+	// 202 Response with generated ID
+	w.WriteHeader(202)
+	w.Write([]byte(options.id))
+}
+
+// generate reads the source datasets and tiles the result into data/{id}/
+func generate(originalDataset string, options options, job *Job) error {
+	var err error
+
+	// Report tiling progress to job
+	progress := func(percent int) {
+		Jobs.SetProgress(job, percent)
+	}
+
+	// TCI Datasets are already 8-bit and can be tiled directly
+	if options.TCI {
+		return HandleTCI(originalDataset, options, progress)
+	}
+
+	// Read Data from source datasets
+	var limits map[string]stretchLimits
+	if options.Expr != "" {
+		limits, err = HandleExpression(options)
+	} else if options.Rgbbool {
+		limits, err = HandleRGB(originalDataset, options)
 	} else {
-		nodata = "0"
+		limits, err = HandleGSC(originalDataset, options)
 	}
+	if err != nil {
+		return err
+	}
+	defer os.Remove(options.id + ".tif")
+
+	// Report value ranges used for the image
+	Jobs.SetResult(job, limits)
 
-	// TCI Tiling is done separately
-	if !options.TCI {
-		// Tiling via gdal2tiles
-		cmd := exec.Command("./gdal2tiles.py", "--resume", "-z", "4-12", "-w", "none", "-a", nodata, options.id+".tif", "data/"+options.id+"/")
-		cmd.Run()
+	// Tiling of temporary GeoTIFF
+	err = tileDataset(options.id+".tif", "data/"+options.id, progress)
+	if err != nil {
+		if Verbose {
+			fmt.Println("Error tiling temporary GeoTIFF File")
+			fmt.Println(err.Error())
+		}
+		return errors.New("Unable to tile image: " + err.Error())
 	}
-	// 200 Response with generated ID
-	w.Write([]byte(options.id))
+	return nil
 }
 
 // HandleRGB handles creation of RGB Images from user-supplied Input Datasets
-func HandleRGB(originalDataset string, options options, w http.ResponseWriter) error {
+func HandleRGB(originalDataset string, options options) (map[string]stretchLimits, error) {
 	var r, g, b []uint16
 	var err error
 
 	// Read red dataset
 	if !options.S2A {
-		r, err = ReadDataFromDatasetL1C(options.Rcn, options.Rcdn, w)
+		r, err = ReadDataFromDatasetL1C(options.Rcn, options.Rcdn)
 	} else {
-		r, err = ReadDataFromDatasetL2A(options.Rcn, options.Rcdn, w)
+		r, err = ReadDataFromDatasetL2A(options.Rcn, options.Rcdn)
 	}
 	if err != nil {
 		if Verbose {
 			fmt.Println("Error reading red dataset")
 			fmt.Println(err.Error())
 		}
-		return err
+		return nil, err
 	}
 
 	// Read green dataset
 	if !options.S2A {
-		g, err = ReadDataFromDatasetL1C(options.Gcn, options.Gcdn, w)
+		g, err = ReadDataFromDatasetL1C(options.Gcn, options.Gcdn)
 	} else {
-		g, err = ReadDataFromDatasetL2A(options.Gcn, options.Gcdn, w)
+		g, err = ReadDataFromDatasetL2A(options.Gcn, options.Gcdn)
 	}
 	if err != nil {
 		if Verbose {
 			fmt.Println("Error reading green dataset")
 			fmt.Println(err.Error())
 		}
-		return err
+		return nil, err
 	}
 
 	// Read blue dataset
 	if !options.S2A {
-		b, err = ReadDataFromDatasetL1C(options.Bcn, options.Bcdn, w)
+		b, err = ReadDataFromDatasetL1C(options.Bcn, options.Bcdn)
 	} else {
-		b, err = ReadDataFromDatasetL2A(options.Bcn, options.Bcdn, w)
+		b, err = ReadDataFromDatasetL2A(options.Bcn, options.Bcdn)
 	}
 	if err != nil {
 		if Verbose {
 			fmt.Println("Error reading blue dataset")
 			fmt.Println(err.Error())
 		}
-		return err
+		return nil, err
+	}
+
+	// Mask selected scene classes
+	if options.Mask != "" {
+		err = maskChannels(options.Mask, []string{options.Rcdn, options.Gcdn, options.Bcdn}, [][]uint16{r, g, b})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Get mapping of each channel to 0-255 space
+	window, err := stretchWindow(originalDataset, options)
+	if err != nil {
+		return nil, err
+	}
+	rlut, rlimits, err := channelLUT(r, options.Rcmin, options.Rcmax, options.Stretch, window)
+	if err != nil {
+		return nil, err
+	}
+	glut, glimits, err := channelLUT(g, options.Gcmin, options.Gcmax, options.Stretch, window)
+	if err != nil {
+		return nil, err
+	}
+	blut, blimits, err := channelLUT(b, options.Bcmin, options.Bcmax, options.Stretch, window)
+	if err != nil {
+		return nil, err
 	}
 
 	// Write all data to temporary tif file
@@ -189,41 +328,59 @@ func HandleRGB(originalDataset string, options options, w http.ResponseWriter) e
 		r,
 		g,
 		b,
-		options.Rcmin,
-		options.Rcmax,
-		options.Gcmin,
-		options.Gcmax,
-		options.Bcmin,
-		options.Bcmax)
-	if err != nil {
-		w.WriteHeader(500)
-		w.Write([]byte("Unable to generate RGB image: " + err.Error()))
+		rlut,
+		glut,
+		blut)
+	if err != nil {
 		if Verbose {
 			fmt.Println("Error writing data to temporary GeoTIFF File")
 			fmt.Println(err.Error())
 		}
-		return err
+		return nil, errors.New("Unable to generate RGB image: " + err.Error())
 	}
-	return nil
+	return map[string]stretchLimits{"red": rlimits, "green": glimits, "blue": blimits}, nil
 }
 
 // HandleGSC handles creation of Greyscale Images from user-supplied Input Dataset
-func HandleGSC(originalDataset string, options options, w http.ResponseWriter) error {
+func HandleGSC(originalDataset string, options options) (map[string]stretchLimits, error) {
 	// Read source data
 	var g []uint16
 	var err error
 
+	palette, err := optionalColormap(options.Cmap)
+	if err != nil {
+		return nil, err
+	}
+
 	if !options.S2A {
-		g, err = ReadDataFromDatasetL1C(options.Gsc, options.Gscdn, w)
+		g, err = ReadDataFromDatasetL1C(options.Gsc, options.Gscdn)
 	} else {
-		g, err = ReadDataFromDatasetL2A(options.Gsc, options.Gscdn, w)
+		g, err = ReadDataFromDatasetL2A(options.Gsc, options.Gscdn)
 	}
 	if err != nil {
 		if Verbose {
 			fmt.Println("Error reading grey dataset")
 			fmt.Println(err.Error())
 		}
-		return err
+		return nil, err
+	}
+
+	// Mask selected scene classes
+	if options.Mask != "" {
+		err = maskChannels(options.Mask, []string{options.Gscdn}, [][]uint16{g})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Get mapping to 0-255 space
+	window, err := stretchWindow(originalDataset, options)
+	if err != nil {
+		return nil, err
+	}
+	lut, limits, err := channelLUT(g, options.Greymin, options.Greymax, options.Stretch, window)
+	if err != nil {
+		return nil, err
 	}
 
 	// Write Data to .tif
@@ -231,74 +388,191 @@ func HandleGSC(originalDataset string, options options, w http.ResponseWriter) e
 		originalDataset,
 		options.id+".tif",
 		g,
-		options.Greymin,
-		options.Greymax)
+		lut,
+		palette)
 	if err != nil {
-		w.WriteHeader(500)
-		w.Write([]byte("Unable to generate Greyscale image: " + err.Error()))
 		if Verbose {
 			fmt.Println("Error writing data to temporary GeoTIFF File")
 			fmt.Println(err.Error())
 		}
-		return err
+		return nil, errors.New("Unable to generate Greyscale image: " + err.Error())
 	}
-	return nil
+	return map[string]stretchLimits{"grey": limits}, nil
+}
+
+// HandleExpression handles creation of Greyscale Images from band math over the bands of one Dataset
+func HandleExpression(options options) (map[string]stretchLimits, error) {
+	expr, err := parseExpression(options.Expr)
+	if err != nil {
+		return nil, err
+	}
+	palette, err := optionalColormap(options.Cmap)
+	if err != nil {
+		return nil, err
+	}
+
+	data, originalDataset, err := ReadExpressionData(expr, options.Gscdn)
+	if err != nil {
+		if Verbose {
+			fmt.Println("Error evaluating expression")
+			fmt.Println(err.Error())
+		}
+		return nil, err
+	}
+
+	// Mask selected scene classes
+	if options.Mask != "" {
+		mask, err := loadSceneMask(productName(options.Gscdn), options.Mask)
+		if err != nil {
+			return nil, errors.New("Unable to load mask: " + err.Error())
+		}
+		mask.applyFloat(data)
+	}
+
+	// Default to value range of normalized indices
+	min, max := options.Greymin, options.Greymax
+	if min == 0 && max == 0 {
+		min, max = -1, 1
+	}
+
+	// Map values to 1-255 space, clamping values outside of bounds like a stretch. Only NaN is written as nodata
+	limits := stretchLimits{min, max}
+	transform := (&contrastStretch{Limits: limits}).apply
+
+	// Compute stretch from data if requested
+	if options.Stretch != "" {
+		window, err := stretchWindow(originalDataset, options)
+		if err != nil {
+			return nil, err
+		}
+		stretch, err := newContrastStretch(options.Stretch, statisticsFloat32(data, window))
+		if err != nil {
+			return nil, err
+		}
+		transform = stretch.apply
+		limits = stretch.Limits
+	}
+
+	// Write Data to .tif
+	err = writeGeoTiffFloat(
+		originalDataset,
+		options.id+".tif",
+		data,
+		transform,
+		palette)
+	if err != nil {
+		if Verbose {
+			fmt.Println("Error writing data to temporary GeoTIFF File")
+			fmt.Println(err.Error())
+		}
+		return nil, errors.New("Unable to generate index image: " + err.Error())
+	}
+	return map[string]stretchLimits{"grey": limits}, nil
 }
 
 // HandleTCI handles Request for True Color Images
-func HandleTCI(originalDataset string, options options, w http.ResponseWriter) error {
+func HandleTCI(originalDataset string, options options, progress func(percent int)) error {
 
 	// Get jp2 location from L1C Dataset
 	if !options.S2A {
 		dataset, err := gdal.Open(originalDataset, gdal.ReadOnly)
 		if err != nil {
-			w.WriteHeader(500)
-			w.Write([]byte("Error opening Dataset: " + err.Error()))
 			if Verbose {
 				fmt.Println("Error opening Dataset by GDAL")
 				fmt.Println(err.Error())
 			}
-			return err
+			return errors.New("Error opening Dataset: " + err.Error())
 		}
 		// Get jp2 Location
 		originalDataset = dataset.FileList()[2]
+		dataset.Close()
 	}
 
 	if Verbose {
-		fmt.Println("Running Tiling Script for Dataset " + originalDataset + "...")
+		fmt.Println("Tiling Dataset " + originalDataset + "...")
+	}
+	err := tileDataset(originalDataset, "data/"+options.id, progress)
+	if err != nil {
+		if Verbose {
+			fmt.Println("Error tiling Dataset")
+			fmt.Println(err.Error())
+		}
+		return errors.New("Unable to tile image: " + err.Error())
 	}
-	// Tiling via gdal2tiles
-	cmd := exec.Command("./gdal2tiles.py", "--resume", "-v", "-z", "4-12", "-w", "none", "-a", "0,0,0", originalDataset, "data/"+options.id+"/")
-	cmd.Run()
 	if Verbose {
-		fmt.Println("... Tiling Script finished.")
+		fmt.Println("... Tiling finished.")
 	}
 	return nil
 }
 
 // ReadDataFromDatasetL2A reads a Sentinel Level 2A Dataset into uint16 slice
-func ReadDataFromDatasetL2A(datasetname, filename string, w http.ResponseWriter) ([]uint16, error) {
+func ReadDataFromDatasetL2A(datasetname, filename string) ([]uint16, error) {
 	defer Timetrack(time.Now(), "Reading Data from Dataset "+filename)
 
-	// Get Name of dynamically named subfolder
-	datalocation := DataSource + filename + "/GRANULE/"
-	subfolder, err := ioutil.ReadDir(datalocation)
+	// Get location of jp2 inside dynamically named subfolder
+	location, err := bandLocation(filename, datasetname)
 	if err != nil {
 		return nil, err
 	}
+	return readBandData(location)
+}
 
-	// Get Resolution
-	resolution := datasetname[len(datasetname)-7 : len(datasetname)-5]
+// ReadExpressionData reads all bands referenced by expr from product and evaluates expr per pixel.
+// Bands of lower resolution are scaled to the highest resolution. Also returns the location of the
+// highest resolution band for georeferencing.
+func ReadExpressionData(expr *expression, product string) ([]float32, string, error) {
+	defer Timetrack(time.Now(), "Evaluating expression on Dataset "+product)
 
+	// Read all referenced bands
+	data := make([][]uint16, len(expr.bands))
+	var originalDataset string
+	maxsize := 0
+	for i, band := range expr.bands {
+		location, err := bandLocation(product, band)
+		if err != nil {
+			return nil, "", errors.New("Unable to find band " + band + ": " + err.Error())
+		}
+		data[i], err = readBandData(location)
+		if err != nil {
+			return nil, "", err
+		}
+		if len(data[i]) > maxsize {
+			maxsize = len(data[i])
+			originalDataset = location
+		}
+	}
+
+	// Scale to highest resolution
+	rowsize := int(math.Sqrt(float64(maxsize)))
+	for i := range data {
+		if len(data[i]) != maxsize {
+			data[i] = upsample(data[i], rowsize)
+		}
+	}
+
+	// Evaluate expression per pixel
+	result := make([]float32, maxsize)
+	dn := make([]uint16, len(data))
+	values := make([]float64, len(data))
+	for p := range result {
+		for i := range data {
+			dn[i] = data[i][p]
+		}
+		result[p] = float32(expr.evalDN(dn, values))
+	}
+	return result, originalDataset, nil
+}
+
+// readBandData reads the first band of the square dataset at location into uint16 slice
+func readBandData(location string) ([]uint16, error) {
 	//Open Dataset via GDAL
-	dataset, err := gdal.Open(DataSource+filename+"/GRANULE/"+subfolder[0].Name()+"/IMG_DATA/R"+resolution+"m/"+datasetname, gdal.ReadOnly)
+	dataset, err := gdal.Open(location, gdal.ReadOnly)
 	if err != nil {
-		w.WriteHeader(500)
-		w.Write([]byte("Error opening Dataset: " + err.Error()))
 		if Verbose {
 			fmt.Println("Error opening Dataset by GDAL")
 			fmt.Println(err.Error())
 		}
+		return nil, errors.New("Error opening Dataset: " + err.Error())
 	}
 	defer dataset.Close()
 
@@ -323,32 +597,29 @@ func ReadDataFromDatasetL2A(datasetname, filename string, w http.ResponseWriter)
 		0,
 		0)
 	if err != nil {
-		w.WriteHeader(500)
-		w.Write([]byte("Error reading data from Dataset: " + err.Error()))
 		if Verbose {
 			fmt.Println("Error reading data from dataset")
 			fmt.Println(err.Error())
 		}
-		return nil, err
+		return nil, errors.New("Error reading data from Dataset: " + err.Error())
 	}
 	// return filled data slice
 	return b, nil
 }
 
 // ReadDataFromDatasetL1C reads a Sentinel Level 1C Dataset into uint16 slice
-func ReadDataFromDatasetL1C(bandname, filename string, w http.ResponseWriter) ([]uint16, error) {
+func ReadDataFromDatasetL1C(bandname, filename string) ([]uint16, error) {
 	defer Timetrack(time.Now(), "Reading Data from Dataset "+filename)
 	// Open Dataset via GDAL
 	dataset, err := gdal.Open(filename, gdal.ReadOnly)
 	if err != nil {
-		w.WriteHeader(500)
-		w.Write([]byte("Error opening Dataset: " + err.Error()))
 		if Verbose {
 			fmt.Println("Error opening Dataset by GDAL")
 			fmt.Println(err.Error())
 		}
-		return nil, err
+		return nil, errors.New("Error opening Dataset: " + err.Error())
 	}
+	defer dataset.Close()
 
 	// map bandname to appropriate bandnumber
 	rasterbands := dataset.RasterCount()
@@ -356,13 +627,11 @@ func ReadDataFromDatasetL1C(bandname, filename string, w http.ResponseWriter) ([
 	for i := 1; i <= rasterbands; i++ {
 		layer, err := dataset.RasterBand(i)
 		if err != nil {
-			w.WriteHeader(500)
-			w.Write([]byte("Error reading rasterband from dataset: " + err.Error()))
 			if Verbose {
 				fmt.Println("Error reading rasterband from dataset")
 				fmt.Println(err.Error())
 			}
-			return nil, err
+			return nil, errors.New("Error reading rasterband from dataset: " + err.Error())
 		}
 		bandstring := strings.Split(layer.Metadata("")[0], "=")
 		if bandstring[1] == bandname {
@@ -371,14 +640,11 @@ func ReadDataFromDatasetL1C(bandname, filename string, w http.ResponseWriter) ([
 	}
 	// check if bandnumber is valid - else invalid bandname was supplied
 	if bandnumber == 0 {
-		w.WriteHeader(400)
-		w.Write([]byte("Invalid Bandname supplied. Band '" + bandname + "' does not exist in Dataset " + filename))
 		if Verbose {
 			fmt.Println("Invalid Bandname supplied. Band '" + bandname + "' does not exist in Dataset " + filename)
 		}
-		return nil, errors.New("dummy")
+		return nil, errors.New("Invalid Bandname supplied. Band '" + bandname + "' does not exist in Dataset " + filename)
 	}
-	defer dataset.Close()
 
 	// get dimensions
 	rasterSize := dataset.RasterXSize()
@@ -401,36 +667,81 @@ func ReadDataFromDatasetL1C(bandname, filename string, w http.ResponseWriter) ([
 		0,
 		0)
 	if err != nil {
-		w.WriteHeader(500)
-		w.Write([]byte("Error reading data from Dataset: " + err.Error()))
 		if Verbose {
 			fmt.Println("Error reading data from dataset")
 			fmt.Println(err.Error())
 		}
-		return nil, err
+		return nil, errors.New("Error reading data from Dataset: " + err.Error())
 	}
 	// return filled buffer
 	return b, err
 }
 
-// writeGeoTiffGrey creates a new TIF File with given data mapped to given bounds
+// writeGeoTiffGrey creates a new TIF File with given data mapped to 8-bit by lut
 func writeGeoTiffGrey(
 	inputdataset, outputdataset string,
 	grey []uint16,
-	mingrey, maxgrey float64,
+	lut []byte,
+	palette colormap,
+) error {
+	// Map original Values to 0-255 space
+	var data8bit = make([]byte, len(grey))
+	transformColorValues(data8bit, grey, lut, len(grey))
+
+	return writeGeoTiffByte(inputdataset, outputdataset, data8bit, palette)
+}
+
+// writeGeoTiffFloat creates a new TIF File with given floating point data mapped to 8-bit by transform.
+// NaN values are written as 0
+func writeGeoTiffFloat(
+	inputdataset, outputdataset string,
+	data []float32,
+	transform func(float64) byte,
+	palette colormap,
 ) error {
-	newdataset, rastersize, err := createGeoTIFF(inputdataset, outputdataset, 1)
+	var data8bit = make([]byte, len(data))
+	for i, v := range data {
+		c := float64(v)
+		if math.IsNaN(c) {
+			continue
+		}
+		data8bit[i] = transform(c)
+	}
+
+	return writeGeoTiffByte(inputdataset, outputdataset, data8bit, palette)
+}
+
+// writeGeoTiffByte writes 8-bit greyscale data to a new TIF File.
+// If palette is set the data is written as RGBA colored by palette.
+func writeGeoTiffByte(inputdataset, outputdataset string, data8bit []byte, palette colormap) error {
+	bandcount := 1
+	if palette != nil {
+		bandcount = 4
+	}
+	newdataset, rastersize, err := createGeoTIFF(inputdataset, outputdataset, bandcount)
 	if err != nil {
 		return err
 	}
 	defer newdataset.Close()
 
-	// Map original Values to 0-255 space
-	var data8bit = make([]byte, len(grey))
-	transformColorValues(data8bit, grey, maxgrey, mingrey, len(grey))
+	// Apply colormap
+	if palette != nil {
+		colors := palette.palette()
+		size := len(data8bit)
+		rgba := make([]byte, 4*size)
+		for i, v := range data8bit {
+			c := colors[v]
+			rgba[i] = c.R
+			rgba[size+i] = c.G
+			rgba[2*size+i] = c.B
+			rgba[3*size+i] = c.A
+		}
+		data8bit = rgba
+	}
 
 	// Write to File
-	newdataset.IO(
+	bands := []int{1, 2, 3, 4}[:bandcount]
+	return newdataset.IO(
 		gdal.Write,
 		0,
 		0,
@@ -439,20 +750,19 @@ func writeGeoTiffGrey(
 		data8bit,
 		rastersize,
 		rastersize,
-		1,
-		[]int{1},
+		bandcount,
+		bands,
 		0,
 		0,
 		0,
 	)
-	return nil
 }
 
 // writeGeoTiffRGB creates a new GeoTIFF File and writes provided r g b values to it
 func writeGeoTiffRGB(
 	inputdataset, outputdataset string,
 	red, green, blue []uint16,
-	minred, maxred, mingreen, maxgreen, minblue, maxblue float64,
+	redlut, greenlut, bluelut []byte,
 ) error {
 	defer Timetrack(time.Now(), "WriteGeoTIFF: ")
 
@@ -480,9 +790,9 @@ func writeGeoTiffRGB(
 	var data8bit = make([]byte, maxresolution*3)
 
 	// Transform all Color values to 0-255 space
-	transformColorValues(data8bit[:maxresolution], red, maxred, minred, maxresolution)
-	transformColorValues(data8bit[maxresolution:2*maxresolution], green, maxgreen, mingreen, maxresolution)
-	transformColorValues(data8bit[2*maxresolution:], blue, maxblue, minblue, maxresolution)
+	transformColorValues(data8bit[:maxresolution], red, redlut, maxresolution)
+	transformColorValues(data8bit[maxresolution:2*maxresolution], green, greenlut, maxresolution)
+	transformColorValues(data8bit[2*maxresolution:], blue, bluelut, maxresolution)
 
 	// Write Data to file
 	newdataset.IO(
@@ -538,11 +848,9 @@ func createGeoTIFF(inputdataset, outputdataset string, bandcount int) (*gdal.Dat
 	return newdataset, rastersize, nil
 }
 
-// transformColorValues transforms given 16-bit values into 8-bit values.
+// transformColorValues transforms given 16-bit values into 8-bit values using lut.
 // If input size is smaller than the desired output size the input is scaled to output by value duplication
-// Linear transform unless original values are outside given bounds, then 0
-func transformColorValues(output []uint8, data []uint16, maxvalue, minvalue float64, newsize int) {
-	delta := sliceDelta(data)
+func transformColorValues(output []uint8, data []uint16, lut []byte, newsize int) {
 	originalrowsize := int(math.Sqrt(float64(len(data))))
 	newrowsize := int(math.Sqrt(float64(newsize)))
 
@@ -587,27 +895,78 @@ func transformColorValues(output []uint8, data []uint16, maxvalue, minvalue floa
 			}
 			runnerX--
 
-			// get original data
-			c := float64(data[runner])
-			// check if value is within bounds
-			if c < minvalue || maxvalue < c {
-				c = 0
-			}
-			// transform to 0-255 space
-			output[i] = (byte)((c / delta) * 255)
+			// transform original data to 0-255 space
+			output[i] = lut[data[runner]]
 		}
 	} else {
 		// Fast transform
 		for i := 0; i < newsize; i++ {
-			c := float64(data[i])
-			// check if value is within bounds
-			if c < minvalue || maxvalue < c {
-				c = 0
-			}
-			// transform to 0-255 space
-			output[i] = (byte)((c / delta) * 255)
+			output[i] = lut[data[i]]
+		}
+	}
+}
+
+// colorLUT returns a lookup table mapping 16-bit values linearly to 0-255 space.
+// Values outside of given bounds are mapped to 0
+func colorLUT(data []uint16, maxvalue, minvalue float64) []byte {
+	delta := sliceDelta(data)
+	lut := make([]byte, math.MaxUint16+1)
+	for i := range lut {
+		c := float64(i)
+		// check if value is within bounds
+		if c < minvalue || maxvalue < c {
+			c = 0
+		}
+		// transform to 0-255 space
+		lut[i] = (byte)((c / delta) * 255)
+	}
+	return lut
+}
+
+// channelLUT returns the lookup table mapping one channel to 0-255 space together with the value range used.
+// Without stretch mode the user supplied bounds are used.
+func channelLUT(data []uint16, minvalue, maxvalue float64, mode string, window *pixelWindow) ([]byte, stretchLimits, error) {
+	if mode == "" {
+		return colorLUT(data, maxvalue, minvalue), stretchLimits{minvalue, maxvalue}, nil
+	}
+	stretch, err := newContrastStretch(mode, statisticsUint16(data, window))
+	if err != nil {
+		return nil, stretchLimits{}, err
+	}
+	return stretch.lut(), stretch.Limits, nil
+}
+
+// stretchWindow returns the part of originalDataset used to compute the stretch, nil for the whole Dataset
+func stretchWindow(originalDataset string, options options) (*pixelWindow, error) {
+	if options.Stretch == "" || options.Bbox == "" {
+		return nil, nil
+	}
+	bbox, err := parseBbox(options.Bbox)
+	if err != nil {
+		return nil, err
+	}
+	return bboxWindow(originalDataset, bbox)
+}
+
+// optionalColormap parses name as colormap, returning nil if name is empty
+func optionalColormap(name string) (colormap, error) {
+	if name == "" {
+		return nil, nil
+	}
+	return parseColormap(name)
+}
+
+// upsample scales square data to newrowsize*newrowsize by value duplication
+func upsample(data []uint16, newrowsize int) []uint16 {
+	rowsize := int(math.Sqrt(float64(len(data))))
+	output := make([]uint16, newrowsize*newrowsize)
+	for row := 0; row < newrowsize; row++ {
+		offset := (row * rowsize / newrowsize) * rowsize
+		for col := 0; col < newrowsize; col++ {
+			output[row*newrowsize+col] = data[offset+col*rowsize/newrowsize]
 		}
 	}
+	return output
 }
 
 // parseOptions parses HTTP-Post Body to options struct
@@ -0,0 +1,275 @@
+package main
+
+import (
+	"errors"
+	"github.com/ling-js/go-gdal"
+	"github.com/paulsmith/gogeos/geos"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Web Mercator constants as used by gdal2tiles
+const (
+	earthRadius = 6378137.0
+	originShift = math.Pi * earthRadius
+	tileSize    = 256
+)
+
+// projection transforms coordinates between WGS84 and the CRS of a raster
+type projection struct {
+	wgs84      gdal.SpatialReference
+	raster     gdal.SpatialReference
+	toRaster   gdal.CoordinateTransform
+	fromRaster gdal.CoordinateTransform
+}
+
+// newProjection creates transformations between WGS84 and the CRS given as WKT
+func newProjection(wkt string) (*projection, error) {
+	if wkt == "" {
+		return nil, errors.New("Dataset has no projection")
+	}
+	p := &projection{}
+	p.wgs84 = gdal.CreateSpatialReference("")
+	err := p.wgs84.FromEPSG(4326)
+	if err != nil {
+		return nil, err
+	}
+	p.raster = gdal.CreateSpatialReference(wkt)
+	p.toRaster = gdal.CreateCoordinateTransform(p.wgs84, p.raster)
+	p.fromRaster = gdal.CreateCoordinateTransform(p.raster, p.wgs84)
+	return p, nil
+}
+
+// Close frees all resources held by the projection
+func (p *projection) Close() {
+	p.toRaster.Destroy()
+	p.fromRaster.Destroy()
+	p.raster.Destroy()
+	p.wgs84.Destroy()
+}
+
+// forward transforms lon/lat coordinates in place into the raster CRS.
+// Points that cannot be transformed are set to +Inf.
+func (p *projection) forward(x, y []float64) {
+	transformPoints(p.toRaster, x, y)
+}
+
+// inverse transforms raster CRS coordinates in place into lon/lat.
+// Points that cannot be transformed are set to +Inf.
+func (p *projection) inverse(x, y []float64) {
+	transformPoints(p.fromRaster, x, y)
+}
+
+// transformPoints applies ct to all points, marking failed points as +Inf
+func transformPoints(ct gdal.CoordinateTransform, x, y []float64) {
+	if len(x) == 0 {
+		return
+	}
+	z := make([]float64, len(x))
+	if !ct.Transform(len(x), x, y, z) {
+		// GDAL sets failed points to HUGE_VAL, make sure they stay unusable
+		for i := range x {
+			if math.IsNaN(x[i]) || math.IsNaN(y[i]) || math.Abs(x[i]) > 1e30 {
+				x[i] = math.Inf(1)
+				y[i] = math.Inf(1)
+			}
+		}
+	}
+}
+
+// invertGeoTransform computes the geotransform mapping georeferenced coordinates to pixel/line
+func invertGeoTransform(gt [6]float64) ([6]float64, error) {
+	var inv [6]float64
+	det := gt[1]*gt[5] - gt[2]*gt[4]
+	if det == 0 {
+		return inv, errors.New("Geotransform is not invertible")
+	}
+	inv[1] = gt[5] / det
+	inv[2] = -gt[2] / det
+	inv[4] = -gt[4] / det
+	inv[5] = gt[1] / det
+	inv[0] = -gt[0]*inv[1] - gt[3]*inv[2]
+	inv[3] = -gt[0]*inv[4] - gt[3]*inv[5]
+	return inv, nil
+}
+
+// applyGeoTransform transforms a coordinate with given geotransform
+func applyGeoTransform(gt [6]float64, x, y float64) (float64, float64) {
+	return gt[0] + x*gt[1] + y*gt[2], gt[3] + x*gt[4] + y*gt[5]
+}
+
+// rasterBounds returns the extent of a raster in lon/lat
+func rasterBounds(p *projection, gt [6]float64, width, height int) (west, south, east, north float64) {
+	// Sample the outline of the raster as it is not rectangular in WGS84
+	var x, y []float64
+	steps := 8
+	for i := 0; i <= steps; i++ {
+		for _, edge := range [][2]float64{
+			{float64(width*i) / float64(steps), 0},
+			{float64(width*i) / float64(steps), float64(height)},
+			{0, float64(height*i) / float64(steps)},
+			{float64(width), float64(height*i) / float64(steps)},
+		} {
+			gx, gy := applyGeoTransform(gt, edge[0], edge[1])
+			x = append(x, gx)
+			y = append(y, gy)
+		}
+	}
+	p.inverse(x, y)
+
+	west, south, east, north = math.Inf(1), math.Inf(1), math.Inf(-1), math.Inf(-1)
+	for i := range x {
+		if math.IsInf(x[i], 0) {
+			continue
+		}
+		west = math.Min(west, x[i])
+		east = math.Max(east, x[i])
+		south = math.Min(south, y[i])
+		north = math.Max(north, y[i])
+	}
+	return west, south, east, north
+}
+
+// lonLatToMercator converts WGS84 coordinates to Web Mercator meters
+func lonLatToMercator(lon, lat float64) (float64, float64) {
+	mx := lon * originShift / 180
+	my := math.Log(math.Tan((90+lat)*math.Pi/360)) / (math.Pi / 180)
+	return mx, my * originShift / 180
+}
+
+// mercatorToLonLat converts Web Mercator meters to WGS84 coordinates
+func mercatorToLonLat(mx, my float64) (float64, float64) {
+	lon := mx / originShift * 180
+	lat := my / originShift * 180
+	lat = 180 / math.Pi * (2*math.Atan(math.Exp(lat*math.Pi/180)) - math.Pi/2)
+	return lon, lat
+}
+
+// tileBounds returns the bounds of TMS tile z/x/y in Web Mercator meters
+func tileBounds(z, x, y int) (minx, miny, maxx, maxy float64) {
+	size := 2 * originShift / math.Exp2(float64(z))
+	minx = float64(x)*size - originShift
+	miny = float64(y)*size - originShift
+	return minx, miny, minx + size, miny + size
+}
+
+// tileRange returns the TMS tiles on zoom level z covering given lon/lat extent
+func tileRange(z int, west, south, east, north float64) (minx, miny, maxx, maxy int) {
+	tiles := int(math.Exp2(float64(z)))
+	size := 2 * originShift / float64(tiles)
+	clamp := func(v int) int {
+		if v < 0 {
+			return 0
+		}
+		if v >= tiles {
+			return tiles - 1
+		}
+		return v
+	}
+	// Stay within valid mercator latitudes
+	south = math.Max(south, -85.0511)
+	north = math.Min(north, 85.0511)
+
+	mx0, my0 := lonLatToMercator(west, south)
+	mx1, my1 := lonLatToMercator(east, north)
+	minx = clamp(int(math.Floor((mx0 + originShift) / size)))
+	miny = clamp(int(math.Floor((my0 + originShift) / size)))
+	maxx = clamp(int(math.Floor((mx1 + originShift) / size)))
+	maxy = clamp(int(math.Floor((my1 + originShift) / size)))
+	return minx, miny, maxx, maxy
+}
+
+// tilePoints returns the lon/lat coordinates of all pixel centers of TMS tile z/x/y in row-major order from the top
+func tilePoints(z, x, y int) (lon, lat []float64) {
+	minx, _, maxx, maxy := tileBounds(z, x, y)
+	resolution := (maxx - minx) / tileSize
+	lon = make([]float64, tileSize*tileSize)
+	lat = make([]float64, tileSize*tileSize)
+	for row := 0; row < tileSize; row++ {
+		my := maxy - (float64(row)+0.5)*resolution
+		for col := 0; col < tileSize; col++ {
+			mx := minx + (float64(col)+0.5)*resolution
+			lon[row*tileSize+col], lat[row*tileSize+col] = mercatorToLonLat(mx, my)
+		}
+	}
+	return lon, lat
+}
+
+// parseBbox parses a bounding box of the form minx,miny,maxx,maxy
+func parseBbox(bbox string) ([4]float64, error) {
+	var b [4]float64
+	coordinates := strings.Split(bbox, ",")
+	if len(coordinates) != 4 {
+		return b, errors.New("Bounding box needs exactly 4 coordinates")
+	}
+	for i := range coordinates {
+		var err error
+		b[i], err = strconv.ParseFloat(strings.TrimSpace(coordinates[i]), 64)
+		if err != nil {
+			return b, errors.New("Invalid coordinate in bounding box: " + err.Error())
+		}
+	}
+	if b[0] > b[2] || b[1] > b[3] {
+		return b, errors.New("Bounding box minimum is larger than maximum")
+	}
+	return b, nil
+}
+
+// bboxGeometry returns a polygon covering a lon/lat bounding box
+func bboxGeometry(b [4]float64) (*geos.Geometry, error) {
+	return geos.NewPolygon([]geos.Coord{
+		{X: b[0], Y: b[1]},
+		{X: b[2], Y: b[1]},
+		{X: b[2], Y: b[3]},
+		{X: b[0], Y: b[3]},
+		{X: b[0], Y: b[1]},
+	})
+}
+
+// bboxWindow returns the part of the raster at location covered by a lon/lat bounding box
+func bboxWindow(location string, bbox [4]float64) (*pixelWindow, error) {
+	dataset, err := gdal.Open(location, gdal.ReadOnly)
+	if err != nil {
+		return nil, errors.New("Error opening Dataset: " + err.Error())
+	}
+	defer dataset.Close()
+	p, err := newProjection(dataset.ProjectionRef())
+	if err != nil {
+		return nil, err
+	}
+	defer p.Close()
+	inverse, err := invertGeoTransform(dataset.GeoTransform())
+	if err != nil {
+		return nil, err
+	}
+
+	// Transform outline of bbox as it is not rectangular in raster CRS
+	var x, y []float64
+	steps := 8
+	for i := 0; i <= steps; i++ {
+		fx := bbox[0] + (bbox[2]-bbox[0])*float64(i)/float64(steps)
+		fy := bbox[1] + (bbox[3]-bbox[1])*float64(i)/float64(steps)
+		x = append(x, fx, fx, bbox[0], bbox[2])
+		y = append(y, bbox[1], bbox[3], fy, fy)
+	}
+	p.forward(x, y)
+
+	w := &pixelWindow{math.Inf(1), math.Inf(1), math.Inf(-1), math.Inf(-1)}
+	width := float64(dataset.RasterXSize())
+	height := float64(dataset.RasterYSize())
+	for i := range x {
+		if math.IsInf(x[i], 0) {
+			continue
+		}
+		col, row := applyGeoTransform(inverse, x[i], y[i])
+		w.X0 = math.Min(w.X0, col/width)
+		w.X1 = math.Max(w.X1, col/width)
+		w.Y0 = math.Min(w.Y0, row/height)
+		w.Y1 = math.Max(w.Y1, row/height)
+	}
+	if w.X1 <= 0 || w.Y1 <= 0 || w.X0 >= 1 || w.Y0 >= 1 {
+		return nil, errors.New("Bounding box does not overlap Dataset")
+	}
+	return w, nil
+}
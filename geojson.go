@@ -0,0 +1,370 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/paulsmith/gogeos/geos"
+	"math"
+	"strings"
+)
+
+// geoJSONGeometry is a GeoJSON geometry object. Coordinates are kept raw as their shape depends on the type
+type geoJSONGeometry struct {
+	Type        string            `json:"type"`
+	Coordinates json.RawMessage   `json:"coordinates,omitempty"`
+	Geometries  []geoJSONGeometry `json:"geometries,omitempty"`
+}
+
+// geoJSONFeature is a GeoJSON Feature
+type geoJSONFeature struct {
+	Type       string                 `json:"type"`
+	ID         interface{}            `json:"id,omitempty"`
+	Geometry   *geoJSONGeometry       `json:"geometry"`
+	Properties map[string]interface{} `json:"properties"`
+}
+
+// parseFeatures parses a GeoJSON FeatureCollection, Feature or geometry into a list of Features
+func parseFeatures(raw json.RawMessage) ([]geoJSONFeature, error) {
+	var object struct {
+		Type     string           `json:"type"`
+		Features []geoJSONFeature `json:"features"`
+	}
+	err := json.Unmarshal(raw, &object)
+	if err != nil {
+		return nil, errors.New("Invalid GeoJSON: " + err.Error())
+	}
+	switch object.Type {
+	case "FeatureCollection":
+		return object.Features, nil
+	case "Feature":
+		var feature geoJSONFeature
+		err = json.Unmarshal(raw, &feature)
+		if err != nil {
+			return nil, errors.New("Invalid GeoJSON: " + err.Error())
+		}
+		return []geoJSONFeature{feature}, nil
+	}
+	var geometry geoJSONGeometry
+	err = json.Unmarshal(raw, &geometry)
+	if err != nil {
+		return nil, errors.New("Invalid GeoJSON: " + err.Error())
+	}
+	return []geoJSONFeature{{Type: "Feature", Geometry: &geometry}}, nil
+}
+
+// parseGeometry parses a geometry given as WKT, GeoJSON geometry or GeoJSON Feature
+func parseGeometry(s string) (*geos.Geometry, error) {
+	s = strings.TrimSpace(s)
+	if !strings.HasPrefix(s, "{") {
+		return geos.FromWKT(s)
+	}
+	var g geoJSONGeometry
+	err := json.Unmarshal([]byte(s), &g)
+	if err != nil {
+		return nil, errors.New("Invalid GeoJSON: " + err.Error())
+	}
+	if g.Type == "Feature" {
+		var feature struct {
+			Geometry *geoJSONGeometry `json:"geometry"`
+		}
+		err = json.Unmarshal([]byte(s), &feature)
+		if err != nil {
+			return nil, errors.New("Invalid GeoJSON: " + err.Error())
+		}
+		if feature.Geometry == nil {
+			return nil, errors.New("GeoJSON Feature without geometry")
+		}
+		g = *feature.Geometry
+	}
+	return geometryFromGeoJSON(g)
+}
+
+// geometryFromGeoJSON converts a GeoJSON geometry into a geos geometry
+func geometryFromGeoJSON(g geoJSONGeometry) (*geos.Geometry, error) {
+	switch g.Type {
+	case "Point":
+		var point []float64
+		err := json.Unmarshal(g.Coordinates, &point)
+		if err != nil {
+			return nil, errors.New("Invalid Point coordinates: " + err.Error())
+		}
+		coords, err := geoJSONCoords([][]float64{point})
+		if err != nil {
+			return nil, err
+		}
+		return geos.NewPoint(coords...)
+	case "LineString":
+		var line [][]float64
+		err := json.Unmarshal(g.Coordinates, &line)
+		if err != nil {
+			return nil, errors.New("Invalid LineString coordinates: " + err.Error())
+		}
+		return geoJSONLineString(line)
+	case "Polygon":
+		var polygon [][][]float64
+		err := json.Unmarshal(g.Coordinates, &polygon)
+		if err != nil {
+			return nil, errors.New("Invalid Polygon coordinates: " + err.Error())
+		}
+		return geoJSONPolygon(polygon)
+	case "MultiPoint":
+		var points [][]float64
+		err := json.Unmarshal(g.Coordinates, &points)
+		if err != nil {
+			return nil, errors.New("Invalid MultiPoint coordinates: " + err.Error())
+		}
+		var geometries []*geos.Geometry
+		for _, point := range points {
+			coords, err := geoJSONCoords([][]float64{point})
+			if err != nil {
+				return nil, err
+			}
+			geometry, err := geos.NewPoint(coords...)
+			if err != nil {
+				return nil, err
+			}
+			geometries = append(geometries, geometry)
+		}
+		return geos.NewCollection(geos.MULTIPOINT, geometries...)
+	case "MultiLineString":
+		var lines [][][]float64
+		err := json.Unmarshal(g.Coordinates, &lines)
+		if err != nil {
+			return nil, errors.New("Invalid MultiLineString coordinates: " + err.Error())
+		}
+		var geometries []*geos.Geometry
+		for _, line := range lines {
+			geometry, err := geoJSONLineString(line)
+			if err != nil {
+				return nil, err
+			}
+			geometries = append(geometries, geometry)
+		}
+		return geos.NewCollection(geos.MULTILINESTRING, geometries...)
+	case "MultiPolygon":
+		var polygons [][][][]float64
+		err := json.Unmarshal(g.Coordinates, &polygons)
+		if err != nil {
+			return nil, errors.New("Invalid MultiPolygon coordinates: " + err.Error())
+		}
+		var geometries []*geos.Geometry
+		for _, polygon := range polygons {
+			geometry, err := geoJSONPolygon(polygon)
+			if err != nil {
+				return nil, err
+			}
+			geometries = append(geometries, geometry)
+		}
+		return geos.NewCollection(geos.MULTIPOLYGON, geometries...)
+	case "GeometryCollection":
+		var geometries []*geos.Geometry
+		for _, member := range g.Geometries {
+			geometry, err := geometryFromGeoJSON(member)
+			if err != nil {
+				return nil, err
+			}
+			geometries = append(geometries, geometry)
+		}
+		return geos.NewCollection(geos.GEOMETRYCOLLECTION, geometries...)
+	}
+	return nil, errors.New("Unsupported GeoJSON geometry type '" + g.Type + "'")
+}
+
+// geoJSONCoords converts GeoJSON positions to geos coordinates
+func geoJSONCoords(positions [][]float64) ([]geos.Coord, error) {
+	coords := make([]geos.Coord, len(positions))
+	for i, position := range positions {
+		if len(position) < 2 {
+			return nil, errors.New("GeoJSON positions need at least two coordinates")
+		}
+		coords[i] = geos.Coord{X: position[0], Y: position[1]}
+	}
+	return coords, nil
+}
+
+func geoJSONLineString(line [][]float64) (*geos.Geometry, error) {
+	coords, err := geoJSONCoords(line)
+	if err != nil {
+		return nil, err
+	}
+	return geos.NewLineString(coords...)
+}
+
+func geoJSONPolygon(polygon [][][]float64) (*geos.Geometry, error) {
+	if len(polygon) == 0 {
+		return nil, errors.New("Polygon without rings")
+	}
+	shell, err := geoJSONCoords(polygon[0])
+	if err != nil {
+		return nil, err
+	}
+	var holes [][]geos.Coord
+	for _, ring := range polygon[1:] {
+		hole, err := geoJSONCoords(ring)
+		if err != nil {
+			return nil, err
+		}
+		holes = append(holes, hole)
+	}
+	return geos.NewPolygon(shell, holes...)
+}
+
+// geoJSONFromGeometry converts a geos geometry into a GeoJSON geometry
+func geoJSONFromGeometry(g *geos.Geometry) (geoJSONGeometry, error) {
+	geometryType, err := g.Type()
+	if err != nil {
+		return geoJSONGeometry{}, err
+	}
+	var coordinates interface{}
+	switch geometryType {
+	case geos.POINT:
+		positions, err := geometryPositions(g)
+		if err != nil {
+			return geoJSONGeometry{}, err
+		}
+		if len(positions) == 0 {
+			return geoJSONGeometry{}, errors.New("Empty points cannot be converted to GeoJSON")
+		}
+		return newGeoJSONGeometry("Point", positions[0])
+	case geos.LINESTRING, geos.LINEARRING:
+		coordinates, err = geometryPositions(g)
+		if err != nil {
+			return geoJSONGeometry{}, err
+		}
+		return newGeoJSONGeometry("LineString", coordinates)
+	case geos.POLYGON:
+		coordinates, err = polygonPositions(g)
+		if err != nil {
+			return geoJSONGeometry{}, err
+		}
+		return newGeoJSONGeometry("Polygon", coordinates)
+	}
+
+	// Convert members of collections
+	n, err := g.NGeometry()
+	if err != nil {
+		return geoJSONGeometry{}, err
+	}
+	var points [][2]float64
+	var lines [][][2]float64
+	var polygons [][][][2]float64
+	var geometries []geoJSONGeometry
+	for i := 0; i < n; i++ {
+		member, err := g.Geometry(i)
+		if err != nil {
+			return geoJSONGeometry{}, err
+		}
+		switch geometryType {
+		case geos.MULTIPOINT:
+			positions, err := geometryPositions(member)
+			if err != nil {
+				return geoJSONGeometry{}, err
+			}
+			points = append(points, positions...)
+		case geos.MULTILINESTRING:
+			positions, err := geometryPositions(member)
+			if err != nil {
+				return geoJSONGeometry{}, err
+			}
+			lines = append(lines, positions)
+		case geos.MULTIPOLYGON:
+			positions, err := polygonPositions(member)
+			if err != nil {
+				return geoJSONGeometry{}, err
+			}
+			polygons = append(polygons, positions)
+		default:
+			geometry, err := geoJSONFromGeometry(member)
+			if err != nil {
+				return geoJSONGeometry{}, err
+			}
+			geometries = append(geometries, geometry)
+		}
+	}
+	switch geometryType {
+	case geos.MULTIPOINT:
+		return newGeoJSONGeometry("MultiPoint", points)
+	case geos.MULTILINESTRING:
+		return newGeoJSONGeometry("MultiLineString", lines)
+	case geos.MULTIPOLYGON:
+		return newGeoJSONGeometry("MultiPolygon", polygons)
+	}
+	return geoJSONGeometry{Type: "GeometryCollection", Geometries: geometries}, nil
+}
+
+func newGeoJSONGeometry(geometryType string, coordinates interface{}) (geoJSONGeometry, error) {
+	raw, err := json.Marshal(coordinates)
+	if err != nil {
+		return geoJSONGeometry{}, err
+	}
+	return geoJSONGeometry{Type: geometryType, Coordinates: raw}, nil
+}
+
+// geometryPositions returns the coordinates of a point, line string or linear ring
+func geometryPositions(g *geos.Geometry) ([][2]float64, error) {
+	coords, err := g.Coords()
+	if err != nil {
+		return nil, err
+	}
+	positions := make([][2]float64, len(coords))
+	for i, c := range coords {
+		positions[i] = [2]float64{c.X, c.Y}
+	}
+	return positions, nil
+}
+
+// polygonPositions returns the coordinates of the shell and all holes of a polygon
+func polygonPositions(g *geos.Geometry) ([][][2]float64, error) {
+	shell, err := g.Shell()
+	if err != nil {
+		return nil, err
+	}
+	holes, err := g.Holes()
+	if err != nil {
+		return nil, err
+	}
+	var rings [][][2]float64
+	for _, ring := range append([]*geos.Geometry{shell}, holes...) {
+		positions, err := geometryPositions(ring)
+		if err != nil {
+			return nil, err
+		}
+		rings = append(rings, positions)
+	}
+	return rings, nil
+}
+
+// geometryBounds returns the bounding box of g as west, south, east, north
+func geometryBounds(g *geos.Geometry) ([4]float64, error) {
+	bounds := [4]float64{math.Inf(1), math.Inf(1), math.Inf(-1), math.Inf(-1)}
+	envelope, err := g.Envelope()
+	if err != nil {
+		return bounds, err
+	}
+
+	// The envelope is a point for single points and a rectangle otherwise
+	envelopeType, err := envelope.Type()
+	if err != nil {
+		return bounds, err
+	}
+	if envelopeType == geos.POLYGON {
+		envelope, err = envelope.Shell()
+		if err != nil {
+			return bounds, err
+		}
+	}
+	coords, err := envelope.Coords()
+	if err != nil {
+		return bounds, err
+	}
+	if len(coords) == 0 {
+		return bounds, errors.New("Cannot compute bounds of empty geometry")
+	}
+	for _, c := range coords {
+		bounds[0] = math.Min(bounds[0], c.X)
+		bounds[1] = math.Min(bounds[1], c.Y)
+		bounds[2] = math.Max(bounds[2], c.X)
+		bounds[3] = math.Max(bounds[3], c.Y)
+	}
+	return bounds, nil
+}
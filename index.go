@@ -0,0 +1,188 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/boltdb/bolt"
+	"io/ioutil"
+	"os"
+	"time"
+)
+
+// Name of the bucket holding all products
+var productBucket = []byte("products")
+
+// ProductIndex is a persistent index of the metadata of all products in DataSource
+type ProductIndex struct {
+	db *bolt.DB
+}
+
+// Index is the global index used by all handlers
+var Index *ProductIndex
+
+// OpenIndex opens or creates the index database at path
+func OpenIndex(path string) (*ProductIndex, error) {
+	db, err := bolt.Open(path, 0644, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, err
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(productBucket)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &ProductIndex{db: db}, nil
+}
+
+// Close closes the index database
+func (i *ProductIndex) Close() error {
+	return i.db.Close()
+}
+
+// Put adds or replaces a product
+func (i *ProductIndex) Put(p *Product) error {
+	productjson, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+	return i.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(productBucket).Put([]byte(p.Name), productjson)
+	})
+}
+
+// Delete removes the product with given name
+func (i *ProductIndex) Delete(name string) error {
+	return i.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(productBucket).Delete([]byte(name))
+	})
+}
+
+// Get returns the product with given name or nil if it is not indexed
+func (i *ProductIndex) Get(name string) (*Product, error) {
+	var p *Product
+	err := i.db.View(func(tx *bolt.Tx) error {
+		productjson := tx.Bucket(productBucket).Get([]byte(name))
+		if productjson == nil {
+			return nil
+		}
+		p = &Product{}
+		return json.Unmarshal(productjson, p)
+	})
+	return p, err
+}
+
+// All returns all indexed products ordered by name
+func (i *ProductIndex) All() ([]*Product, error) {
+	var products []*Product
+	err := i.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(productBucket).ForEach(func(k, v []byte) error {
+			p := &Product{}
+			err := json.Unmarshal(v, p)
+			if err != nil {
+				return err
+			}
+			products = append(products, p)
+			return nil
+		})
+	})
+	return products, err
+}
+
+// Sync brings the index up to date with the products in DataSource.
+// New and changed products are read, removed products are deleted from the index.
+func (i *ProductIndex) Sync() error {
+	defer Timetrack(time.Now(), "Index Sync")
+	entries, err := ioutil.ReadDir(DataSource)
+	if err != nil {
+		return err
+	}
+	indexed, err := i.All()
+	if err != nil {
+		return err
+	}
+	known := make(map[string]*Product)
+	for _, p := range indexed {
+		known[p.Name] = p
+	}
+
+	// Add new and changed products
+	present := make(map[string]bool)
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		name := entry.Name()
+		present[name] = true
+
+		_, modified, err := metadataFile(name)
+		p, ok := known[name]
+		if err != nil && !ok {
+			if Verbose {
+				fmt.Println("Skipping " + name + ": " + err.Error())
+			}
+			continue
+		}
+		if err == nil && ok && p.Version == productVersion && p.Modified.Equal(modified) {
+			continue
+		}
+		err = i.Update(name)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Remove products no longer present
+	for name := range known {
+		if !present[name] {
+			err = i.Delete(name)
+			if err != nil {
+				return err
+			}
+			if Verbose {
+				fmt.Println("Removed " + name + " from index")
+			}
+		}
+	}
+	return nil
+}
+
+// Update reads the product with given name from DataSource and adds it to the index.
+// Products that were removed or cannot be read are removed from the index, so broken products are never listed.
+func (i *ProductIndex) Update(name string) error {
+	info, err := os.Stat(DataSource + name)
+	if err != nil || !info.IsDir() {
+		if Verbose {
+			fmt.Println("Removed " + name + " from index")
+		}
+		return i.Delete(name)
+	}
+
+	p, err := readProduct(name)
+	if err != nil {
+		if Verbose {
+			fmt.Println("Rejected " + name + ": " + err.Error())
+		}
+		return i.Delete(name)
+	}
+	err = i.Put(p)
+	if err != nil {
+		return err
+	}
+	if Verbose {
+		fmt.Println("Indexed " + name)
+	}
+	return nil
+}
+
+// SyncPeriodically keeps the index up to date by syncing it every interval
+func (i *ProductIndex) SyncPeriodically(interval time.Duration) {
+	for range time.Tick(interval) {
+		err := i.Sync()
+		if err != nil {
+			fmt.Println("Unable to sync index: " + err.Error())
+		}
+	}
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/julienschmidt/httprouter"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Possible states of a job
+const (
+	JobQueued  = "queued"
+	JobRunning = "running"
+	JobFailed  = "failed"
+	JobDone    = "done"
+)
+
+// ErrQueueFull is returned when no more jobs can be enqueued
+var ErrQueueFull = errors.New("Job queue is full")
+
+// Job holds the status of a single generation task
+type Job struct {
+	ID       string      `json:"id"`
+	Status   string      `json:"status"`
+	Progress int         `json:"progress"`
+	Error    string      `json:"error,omitempty"`
+	Result   interface{} `json:"result,omitempty"`
+	Queued   time.Time   `json:"queued"`
+	Started  *time.Time  `json:"started,omitempty"`
+	Finished *time.Time  `json:"finished,omitempty"`
+
+	// work to be done by the worker
+	task func(job *Job) error
+}
+
+// JobQueue runs enqueued Jobs in a bounded pool of workers.
+// Finished jobs are kept for ttl and evicted afterwards.
+type JobQueue struct {
+	mutex sync.RWMutex
+	jobs  map[string]*Job
+	queue chan *Job
+	ttl   time.Duration
+
+	// now returns the current time, replaced in tests
+	now func() time.Time
+}
+
+// Jobs is the global queue used by all handlers
+var Jobs *JobQueue
+
+// NewJobQueue creates a JobQueue with given number of workers, queue length and time to keep finished jobs
+func NewJobQueue(workers, length int, ttl time.Duration) *JobQueue {
+	q := &JobQueue{
+		jobs:  make(map[string]*Job),
+		queue: make(chan *Job, length),
+		ttl:   ttl,
+		now:   time.Now,
+	}
+	for i := 0; i < workers; i++ {
+		go q.work()
+	}
+	return q
+}
+
+// Enqueue adds a new job with given id. Returns ErrQueueFull if queue has no capacity left
+func (q *JobQueue) Enqueue(id string, task func(job *Job) error) (*Job, error) {
+	job := &Job{
+		ID:     id,
+		Status: JobQueued,
+		Queued: q.now(),
+		task:   task,
+	}
+
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	q.evict()
+	select {
+	case q.queue <- job:
+		q.jobs[id] = job
+		return job, nil
+	default:
+		return nil, ErrQueueFull
+	}
+}
+
+// Get returns a copy of the job with given id
+func (q *JobQueue) Get(id string) (Job, bool) {
+	q.mutex.RLock()
+	defer q.mutex.RUnlock()
+	job, ok := q.jobs[id]
+	if !ok || q.expired(job) {
+		return Job{}, false
+	}
+	return *job, true
+}
+
+// evict removes all expired jobs, the caller must hold the write lock
+func (q *JobQueue) evict() {
+	for id, job := range q.jobs {
+		if q.expired(job) {
+			delete(q.jobs, id)
+		}
+	}
+}
+
+// expired reports whether job finished more than ttl ago
+func (q *JobQueue) expired(job *Job) bool {
+	return job.Finished != nil && q.now().Sub(*job.Finished) > q.ttl
+}
+
+// work executes jobs from the queue until the queue is closed
+func (q *JobQueue) work() {
+	for job := range q.queue {
+		q.setStatus(job, JobRunning, nil)
+		err := job.task(job)
+		if err != nil {
+			if Verbose {
+				fmt.Println("Job " + job.ID + " failed: " + err.Error())
+			}
+			q.setStatus(job, JobFailed, err)
+		} else {
+			q.setStatus(job, JobDone, nil)
+		}
+	}
+}
+
+// SetProgress updates the completion percentage of a job
+func (q *JobQueue) SetProgress(job *Job, percent int) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	job.Progress = percent
+}
+
+// SetResult stores the result of a job to be returned with its status
+func (q *JobQueue) SetResult(job *Job, result interface{}) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	job.Result = result
+}
+
+// setStatus updates status and timestamps of a job
+func (q *JobQueue) setStatus(job *Job, status string, err error) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	now := q.now()
+	job.Status = status
+	switch status {
+	case JobRunning:
+		job.Started = &now
+	case JobDone:
+		job.Finished = &now
+		job.Progress = 100
+	case JobFailed:
+		job.Finished = &now
+	}
+	if err != nil {
+		job.Error = err.Error()
+	}
+}
+
+// JobHandler returns the status of the job with given id
+func JobHandler(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	id := ps.ByName("id")
+
+	// Log request if verbose is set
+	if Verbose {
+		fmt.Println("Request to /jobs/" + id)
+	}
+
+	job, ok := Jobs.Get(id)
+	if !ok {
+		w.WriteHeader(404)
+		w.Write([]byte("Cannot find Job " + id))
+		return
+	}
+
+	jobjson, err := json.Marshal(job)
+	if err != nil {
+		w.WriteHeader(500)
+		w.Write([]byte("Unable to encode Job: " + err.Error()))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(jobjson)
+}
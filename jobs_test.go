@@ -0,0 +1,98 @@
+package main
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeClock is a manually advanced clock for JobQueue.now
+type fakeClock struct {
+	mutex sync.Mutex
+	time  time.Time
+}
+
+func (c *fakeClock) now() time.Time {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.time
+}
+
+func (c *fakeClock) advance(d time.Duration) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.time = c.time.Add(d)
+}
+
+// newTestJobQueue creates a JobQueue using a fake clock
+func newTestJobQueue(ttl time.Duration) (*JobQueue, *fakeClock) {
+	clock := &fakeClock{time: time.Date(2018, 5, 1, 12, 0, 0, 0, time.UTC)}
+	q := NewJobQueue(1, 4, ttl)
+	q.now = clock.now
+	return q, clock
+}
+
+func TestJobQueueEvictsFinishedJobs(t *testing.T) {
+	q, clock := newTestJobQueue(time.Minute)
+	done := make(chan bool)
+	_, err := q.Enqueue("first", func(job *Job) error {
+		q.SetResult(job, "result")
+		done <- true
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-done
+
+	// Wait until the worker marked the job as done
+	for i := 0; i < 100; i++ {
+		job, ok := q.Get("first")
+		if ok && job.Status == JobDone {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	job, ok := q.Get("first")
+	if !ok || job.Status != JobDone || job.Result != "result" {
+		t.Fatalf("Get(first) = %+v, %v, want finished job with result", job, ok)
+	}
+
+	clock.advance(time.Minute)
+	if _, ok := q.Get("first"); !ok {
+		t.Error("Get(first) did not return job within ttl")
+	}
+	clock.advance(time.Second)
+	if _, ok := q.Get("first"); ok {
+		t.Error("Get(first) returned job after ttl")
+	}
+
+	// Expired jobs are removed from the map on the next enqueue
+	_, err = q.Enqueue("second", func(job *Job) error { return nil })
+	if err != nil {
+		t.Fatal(err)
+	}
+	q.mutex.RLock()
+	_, ok = q.jobs["first"]
+	q.mutex.RUnlock()
+	if ok {
+		t.Error("expired job was not evicted")
+	}
+}
+
+func TestJobQueueKeepsRunningJobs(t *testing.T) {
+	q, clock := newTestJobQueue(time.Minute)
+	release := make(chan bool)
+	_, err := q.Enqueue("running", func(job *Job) error {
+		<-release
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer close(release)
+	clock.advance(time.Hour)
+	if _, ok := q.Get("running"); !ok {
+		t.Error("unfinished job was evicted")
+	}
+}
@@ -0,0 +1,291 @@
+package main
+
+import (
+	"encoding/xml"
+	"errors"
+	"github.com/ling-js/go-gdal"
+	"io/ioutil"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Number of classes in the L2A scene classification
+const sceneClassCount = 12
+
+// SceneClasses maps names of L2A scene classification (SCL) classes to their values
+var SceneClasses = map[string]int{
+	"nodata":       0,
+	"saturated":    1,
+	"dark":         2,
+	"shadow":       3,
+	"vegetation":   4,
+	"bare":         5,
+	"water":        6,
+	"unclassified": 7,
+	"cloud_medium": 8,
+	"cloud_high":   9,
+	"cirrus":       10,
+	"snow":         11,
+}
+
+// sceneClassName returns the name of a scene class value
+func sceneClassName(class int) string {
+	for name, value := range SceneClasses {
+		if value == class {
+			return name
+		}
+	}
+	return ""
+}
+
+// L1C cloud masks only distinguish opaque clouds and cirrus
+var l1cMaskTypes = map[int]string{
+	SceneClasses["cloud_medium"]: "OPAQUE",
+	SceneClasses["cloud_high"]:   "OPAQUE",
+	SceneClasses["cirrus"]:       "CIRRUS",
+}
+
+// Response Schemata of L1C MSK_CLOUDS_B00.gml
+type cloudMask struct {
+	Features []cloudMaskFeature `xml:"maskMembers>MaskFeature"`
+}
+
+type cloudMaskFeature struct {
+	Type      string   `xml:"maskType"`
+	Exterior  string   `xml:"extentOf>Polygon>exterior>LinearRing>posList"`
+	Interiors []string `xml:"extentOf>Polygon>interior>LinearRing>posList"`
+}
+
+// sceneMask marks pixels of a product belonging to unwanted scene classes
+type sceneMask struct {
+	// L2A: scene classification and classes to mask
+	scl     []uint16
+	classes [sceneClassCount]bool
+
+	// L1C: cloud polygons in pixel coordinates of a size*size grid
+	polygons [][][][2]float64
+	size     int
+}
+
+// parseMaskClasses parses a comma separated list of SCL class names or values
+func parseMaskClasses(mask string) ([]int, error) {
+	var classes []int
+	for _, name := range strings.Split(mask, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if class, ok := SceneClasses[name]; ok {
+			classes = append(classes, class)
+			continue
+		}
+		class, err := strconv.Atoi(name)
+		if err != nil || class < 0 || class >= sceneClassCount {
+			return nil, errors.New("Unknown scene class '" + name + "'")
+		}
+		classes = append(classes, class)
+	}
+	return classes, nil
+}
+
+// l1cMaskClasses returns the classes of mask that the cloud mask of L1C products can represent, dropping all others.
+// Returns an error if none of the classes can be masked in L1C products.
+func l1cMaskClasses(mask string) (string, error) {
+	classes, err := parseMaskClasses(mask)
+	if err != nil {
+		return "", err
+	}
+	var supported []string
+	for _, class := range classes {
+		if _, ok := l1cMaskTypes[class]; ok {
+			supported = append(supported, sceneClassName(class))
+		}
+	}
+	if len(supported) == 0 {
+		return "", errors.New("None of the scene classes '" + mask + "' can be masked in L1C products")
+	}
+	return strings.Join(supported, ","), nil
+}
+
+// loadSceneMask loads the SCL band of a L2A product or the cloud mask of a L1C product
+func loadSceneMask(product, mask string) (*sceneMask, error) {
+	classes, err := parseMaskClasses(mask)
+	if err != nil {
+		return nil, err
+	}
+	m := &sceneMask{}
+
+	// Use scene classification of L2A products
+	location, err := bandLocation(product, "SCL")
+	if err == nil {
+		m.scl, err = readBandData(location)
+		if err != nil {
+			return nil, err
+		}
+		for _, class := range classes {
+			m.classes[class] = true
+		}
+		return m, nil
+	}
+	if err != ErrBandNotFound {
+		return nil, err
+	}
+
+	// Fall back to cloud polygons of L1C products
+	types := make(map[string]bool)
+	for _, class := range classes {
+		maskType, ok := l1cMaskTypes[class]
+		if !ok {
+			return nil, errors.New("Scene class " + strconv.Itoa(class) + " can only be masked in L2A products")
+		}
+		types[maskType] = true
+	}
+	err = m.loadCloudPolygons(product, types)
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// loadCloudPolygons reads all polygons of given types from the L1C cloud mask and converts them to pixel coordinates
+func (m *sceneMask) loadCloudPolygons(product string, types map[string]bool) error {
+	granule, err := granuleLocation(productName(product))
+	if err != nil {
+		return err
+	}
+	content, err := ioutil.ReadFile(granule + "QI_DATA/MSK_CLOUDS_B00.gml")
+	if err != nil {
+		return err
+	}
+	var v cloudMask
+	err = xml.Unmarshal(content, &v)
+	if err != nil {
+		return errors.New("Error parsing cloud mask: " + err.Error())
+	}
+
+	// Polygons are given in the CRS of the product, use georeference of the 10m grid
+	location, err := bandLocation(product, "B02")
+	if err != nil {
+		return err
+	}
+	dataset, err := gdal.Open(location, gdal.ReadOnly)
+	if err != nil {
+		return errors.New("Error opening Dataset: " + err.Error())
+	}
+	defer dataset.Close()
+	m.size = dataset.RasterXSize()
+	inverse, err := invertGeoTransform(dataset.GeoTransform())
+	if err != nil {
+		return err
+	}
+
+	for _, feature := range v.Features {
+		if !types[feature.Type] {
+			continue
+		}
+		var rings [][][2]float64
+		for _, posList := range append([]string{feature.Exterior}, feature.Interiors...) {
+			ring, err := parsePosList(posList, inverse)
+			if err != nil {
+				return err
+			}
+			rings = append(rings, ring)
+		}
+		m.polygons = append(m.polygons, rings)
+	}
+	return nil
+}
+
+// parsePosList parses a GML posList and converts its coordinates to pixel coordinates
+func parsePosList(posList string, inverse [6]float64) ([][2]float64, error) {
+	fields := strings.Fields(posList)
+	ring := make([][2]float64, len(fields)/2)
+	for i := range ring {
+		x, err := strconv.ParseFloat(fields[2*i], 64)
+		if err != nil {
+			return nil, errors.New("Invalid coordinate in cloud mask: " + err.Error())
+		}
+		y, err := strconv.ParseFloat(fields[2*i+1], 64)
+		if err != nil {
+			return nil, errors.New("Invalid coordinate in cloud mask: " + err.Error())
+		}
+		ring[i][0], ring[i][1] = applyGeoTransform(inverse, x, y)
+	}
+	return ring, nil
+}
+
+// resample returns for every pixel of a square raster with given rowsize if it is masked
+func (m *sceneMask) resample(rowsize int) []bool {
+	masked := make([]bool, rowsize*rowsize)
+	if m.scl != nil {
+		scl := m.scl
+		if len(scl) != len(masked) {
+			scl = upsample(scl, rowsize)
+		}
+		for i, class := range scl {
+			masked[i] = int(class) < len(m.classes) && m.classes[class]
+		}
+		return masked
+	}
+	return m.polygonWindow(zoneWindow{0, 0, rowsize, rowsize, rowsize, rowsize})
+}
+
+// polygonWindow rasterizes the L1C cloud polygons into window w
+func (m *sceneMask) polygonWindow(w zoneWindow) []bool {
+	cols, rows := w.size()
+	masked := make([]bool, cols*rows)
+
+	// Scale polygons from 10m grid to the grid of the window
+	scalex := float64(w.width) / float64(m.size)
+	scaley := float64(w.height) / float64(m.size)
+	for _, polygon := range m.polygons {
+		rings := make([][][2]float64, len(polygon))
+		for r := range polygon {
+			rings[r] = make([][2]float64, len(polygon[r]))
+			for i, p := range polygon[r] {
+				rings[r][i] = [2]float64{p[0]*scalex - float64(w.x0), p[1]*scaley - float64(w.y0)}
+			}
+		}
+		rasterizePolygon(rings, cols, rows, func(x, y int) {
+			masked[y*cols+x] = true
+		})
+	}
+	return masked
+}
+
+// apply sets all masked pixels of a square band to nodata
+func (m *sceneMask) apply(data []uint16) {
+	masked := m.resample(int(math.Sqrt(float64(len(data)))))
+	for i := range data {
+		if masked[i] {
+			data[i] = 0
+		}
+	}
+}
+
+// applyFloat sets all masked pixels of a square band to NaN
+func (m *sceneMask) applyFloat(data []float32) {
+	masked := m.resample(int(math.Sqrt(float64(len(data)))))
+	for i := range data {
+		if masked[i] {
+			data[i] = float32(math.NaN())
+		}
+	}
+}
+
+// maskChannels applies mask to every channel, loading the mask of each product only once
+func maskChannels(mask string, products []string, channels [][]uint16) error {
+	masks := make(map[string]*sceneMask)
+	for i := range channels {
+		product := productName(products[i])
+		m, ok := masks[product]
+		if !ok {
+			var err error
+			m, err = loadSceneMask(product, mask)
+			if err != nil {
+				return errors.New("Unable to load mask of " + product + ": " + err.Error())
+			}
+			masks[product] = m
+		}
+		m.apply(channels[i])
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseMaskClasses(t *testing.T) {
+	tests := []struct {
+		mask string
+		want []int
+	}{
+		{"cloud_high", []int{9}},
+		{"shadow, Cloud_Medium,cirrus", []int{3, 8, 10}},
+		{"3,8", []int{3, 8}},
+		{"snow,0", []int{11, 0}},
+	}
+	for _, test := range tests {
+		got, err := parseMaskClasses(test.mask)
+		if err != nil {
+			t.Errorf("parseMaskClasses(%q) failed: %v", test.mask, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("parseMaskClasses(%q) = %v, want %v", test.mask, got, test.want)
+		}
+	}
+	for _, mask := range []string{"", "clouds", "12", "-1", "cirrus,"} {
+		if _, err := parseMaskClasses(mask); err == nil {
+			t.Errorf("parseMaskClasses(%q) succeeded, want error", mask)
+		}
+	}
+}
+
+func TestParsePosList(t *testing.T) {
+	inverse, err := invertGeoTransform([6]float64{600000, 10, 0, 5700000, 0, -10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ring, err := parsePosList("600000 5700000 600100 5700000  600100 5699900", inverse)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][2]float64{{0, 0}, {10, 0}, {10, 10}}
+	if !reflect.DeepEqual(ring, want) {
+		t.Errorf("parsePosList = %v, want %v", ring, want)
+	}
+	if _, err := parsePosList("600000 north", inverse); err == nil {
+		t.Error("parsePosList with invalid coordinate succeeded, want error")
+	}
+}
+
+func TestSceneMaskResample(t *testing.T) {
+	m := &sceneMask{scl: []uint16{4, 9, 3, 0}}
+	m.classes[9] = true
+	m.classes[3] = true
+
+	got := m.resample(4)
+	want := []bool{
+		false, false, true, true,
+		false, false, true, true,
+		true, true, false, false,
+		true, true, false, false,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("resample(4) = %v, want %v", got, want)
+	}
+
+	// Cloud polygons of L1C products are given on a 10m grid
+	m = &sceneMask{size: 4, polygons: [][][][2]float64{{{{0, 0}, {2, 0}, {2, 2}, {0, 2}}}}}
+	got = m.resample(2)
+	want = []bool{true, false, false, false}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("resample of polygons = %v, want %v", got, want)
+	}
+
+	// Windows of a 20m grid only rasterize their own pixels
+	m = &sceneMask{size: 8, polygons: [][][][2]float64{{{{2, 2}, {6, 2}, {6, 6}, {2, 6}}}}}
+	got = m.polygonWindow(zoneWindow{x0: 2, y0: 1, x1: 4, y1: 3, width: 4, height: 4})
+	want = []bool{true, false, true, false}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("polygonWindow = %v, want %v", got, want)
+	}
+}
+
+func TestSceneClassName(t *testing.T) {
+	for name, class := range SceneClasses {
+		if got := sceneClassName(class); got != name {
+			t.Errorf("sceneClassName(%d) = %q, want %q", class, got, name)
+		}
+	}
+	if got := sceneClassName(sceneClassCount); got != "" {
+		t.Errorf("sceneClassName(%d) = %q, want empty name", sceneClassCount, got)
+	}
+}
+
+func TestL1CMaskClasses(t *testing.T) {
+	tests := []struct {
+		mask, want string
+	}{
+		{"shadow,cloud_medium,cloud_high,cirrus", "cloud_medium,cloud_high,cirrus"},
+		{"cirrus", "cirrus"},
+		{"9,snow", "cloud_high"},
+	}
+	for _, test := range tests {
+		got, err := l1cMaskClasses(test.mask)
+		if err != nil || got != test.want {
+			t.Errorf("l1cMaskClasses(%q) = %q, %v, want %q", test.mask, got, err, test.want)
+		}
+	}
+	for _, mask := range []string{"shadow", "water,snow", "unknown"} {
+		if _, err := l1cMaskClasses(mask); err == nil {
+			t.Errorf("l1cMaskClasses(%q) succeeded, want error", mask)
+		}
+	}
+}
@@ -0,0 +1,432 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"github.com/ling-js/go-gdal"
+	"image"
+	"image/color"
+	"math"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// Orders of products in mosaics, the first product is drawn on top
+const (
+	MosaicLatest      = "latest"
+	MosaicLeastCloudy = "cloud"
+)
+
+// Maximum number of products combined into one mosaic
+const mosaicMaxProducts = 50
+
+// mosaicRenderer renders tiles from several products, each reprojected from its own CRS.
+// Pixels of earlier renderers are drawn on top of later ones.
+type mosaicRenderer struct {
+	renderers []*rasterRenderer
+}
+
+// Close closes all renderers of the mosaic
+func (m *mosaicRenderer) Close() {
+	for _, r := range m.renderers {
+		r.Close()
+	}
+}
+
+func (m *mosaicRenderer) bounds() (west, south, east, north float64) {
+	west, south = math.Inf(1), math.Inf(1)
+	east, north = math.Inf(-1), math.Inf(-1)
+	for _, r := range m.renderers {
+		w, s, e, n := r.bounds()
+		west, south = math.Min(west, w), math.Min(south, s)
+		east, north = math.Max(east, e), math.Max(north, n)
+	}
+	return west, south, east, north
+}
+
+func (m *mosaicRenderer) renderTile(z, x, y int) (*image.RGBA, error) {
+	var mosaic *image.RGBA
+	transparent := tileSize * tileSize
+	for _, r := range m.renderers {
+		// Skip products not overlapping the tile
+		west, south, east, north := r.bounds()
+		minx, miny, maxx, maxy := tileRange(z, west, south, east, north)
+		if x < minx || x > maxx || y < miny || y > maxy {
+			continue
+		}
+
+		img, err := r.renderTile(z, x, y)
+		if err != nil {
+			return nil, err
+		}
+		if img == nil {
+			continue
+		}
+		if mosaic == nil {
+			mosaic = img
+			transparent = 0
+			for i := 3; i < len(img.Pix); i += 4 {
+				if img.Pix[i] == 0 {
+					transparent++
+				}
+			}
+		} else {
+			// Fill pixels left transparent by products on top
+			for i := 3; i < len(mosaic.Pix); i += 4 {
+				if mosaic.Pix[i] == 0 && img.Pix[i] != 0 {
+					copy(mosaic.Pix[i-3:i+1], img.Pix[i-3:i+1])
+					transparent--
+				}
+			}
+		}
+		if transparent == 0 {
+			break
+		}
+	}
+	return mosaic, nil
+}
+
+// addMask hides all pixels whose scene class in the SCL band at location is one of classes
+func (r *rasterRenderer) addMask(location string, classes []int) error {
+	dataset, err := gdal.Open(location, gdal.ReadOnly)
+	if err != nil {
+		return errors.New("Error opening Dataset " + location + ": " + err.Error())
+	}
+	inverse, err := invertGeoTransform(dataset.GeoTransform())
+	if err != nil {
+		dataset.Close()
+		return err
+	}
+	r.layers = append(r.layers, rasterLayer{
+		dataset: dataset,
+		band:    1,
+		inverse: inverse,
+		width:   dataset.RasterXSize(),
+		height:  dataset.RasterYSize(),
+	})
+
+	var masked [sceneClassCount]bool
+	for _, class := range classes {
+		masked[class] = true
+	}
+	colorize := r.colorize
+	r.colorize = func(values []uint16) color.RGBA {
+		class := values[len(values)-1]
+		if class == math.MaxUint16 || (int(class) < sceneClassCount && masked[class]) {
+			return color.RGBA{}
+		}
+		return colorize(values[:len(values)-1])
+	}
+	return nil
+}
+
+// mosaicProducts returns the products selected by options.Products or options.Search in the requested order
+func mosaicProducts(options options) ([]*Product, error) {
+	var products []*Product
+	if options.Products != "" {
+		for _, name := range strings.Split(options.Products, ",") {
+			p, err := Index.Get(productName(strings.TrimSpace(name)))
+			if err != nil {
+				return nil, err
+			}
+			if p == nil {
+				return nil, errors.New("Cannot find Dataset " + name)
+			}
+			if options.Mask != "" && p.Level != "L2A" {
+				return nil, errors.New("Dataset " + p.Name + " is " + p.Level + ", masking mosaics requires L2A products")
+			}
+			products = append(products, p)
+		}
+	} else {
+		q, err := url.ParseQuery(options.Search)
+		if err != nil {
+			return nil, errors.New("Unable to parse search: " + err.Error())
+		}
+
+		// Masks are read from the scene classification only available in L2A products
+		if options.Mask != "" {
+			if q.Get("level") != "" {
+				level, err := normalizeLevel(q.Get("level"))
+				if err != nil {
+					return nil, err
+				}
+				if level != "L2A" {
+					return nil, errors.New("Masking mosaics requires L2A products, got level " + level)
+				}
+			}
+			q.Set("level", "L2A")
+		}
+		products, err = searchProducts(q)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(products) == 0 {
+		return nil, errors.New("No products match the mosaic")
+	}
+	if len(products) > mosaicMaxProducts {
+		return nil, errors.New("Mosaics can combine at most " + strconv.Itoa(mosaicMaxProducts) + " products, got " + strconv.Itoa(len(products)))
+	}
+
+	var err error
+	switch options.Order {
+	case "", MosaicLatest:
+		_, err = sortProducts(products, "date", "desc")
+	case MosaicLeastCloudy:
+		_, err = sortProducts(products, "cloud", "asc")
+	default:
+		err = errors.New("Unknown mosaic order '" + options.Order + "', use " + MosaicLatest + " or " + MosaicLeastCloudy)
+	}
+	return products, err
+}
+
+// searchProducts returns all products matching the filters of a /search query
+func searchProducts(q url.Values) ([]*Product, error) {
+	datasets, err := Index.All()
+	if err != nil {
+		return nil, errors.New("Unable to read Index: " + err.Error())
+	}
+	spatial, err := parseSpatialFilter(q)
+	if err != nil {
+		return nil, err
+	}
+	err = nameFilter(datasets, q.Get("substring"))
+	if err != nil {
+		return nil, err
+	}
+	err = propertiesFilter(datasets, q)
+	if err != nil {
+		return nil, err
+	}
+	filterDates := q.Get("startdate") != "" && q.Get("enddate") != ""
+	if filterDates || spatial != nil {
+		err = metaDataFilter(datasets, q.Get("startdate"), q.Get("enddate"), spatial, filterDates)
+		if err != nil {
+			return nil, errors.New("Unable to filter by metadata: " + err.Error())
+		}
+	}
+
+	var products []*Product
+	for _, dataset := range datasets {
+		if dataset != nil {
+			products = append(products, dataset)
+		}
+	}
+	return products, nil
+}
+
+// mosaicBands returns the bands of every product rendered for options together with the names of the channels
+func mosaicBands(options options) (bands, channels []string) {
+	if options.Rgbbool {
+		return []string{options.Rcn, options.Gcn, options.Bcn}, []string{"red", "green", "blue"}
+	}
+	return []string{options.Gsc}, []string{"grey"}
+}
+
+// mosaicLimits computes the stretch limits of all channels over all products, so that all products share the same stretch.
+// The limits of a channel span the limits computed for each product. Without stretch mode the user supplied bounds are used.
+func mosaicLimits(products []*Product, options options) (map[string]stretchLimits, error) {
+	if options.Expr != "" {
+		limits, err := mosaicExpressionLimits(products, options)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]stretchLimits{"grey": limits}, nil
+	}
+
+	bands, channels := mosaicBands(options)
+	limits := map[string]stretchLimits{
+		"red":   {options.Rcmin, options.Rcmax},
+		"green": {options.Gcmin, options.Gcmax},
+		"blue":  {options.Bcmin, options.Bcmax},
+		"grey":  {options.Greymin, options.Greymax},
+	}
+	result := make(map[string]stretchLimits)
+	for i, band := range bands {
+		if options.Stretch == "" {
+			result[channels[i]] = limits[channels[i]]
+			continue
+		}
+		union := emptyLimits()
+		for _, p := range products {
+			location, err := bandLocation(p.Name, band)
+			if err != nil {
+				return nil, err
+			}
+			stats, err := bandStatisticsOf(location)
+			if err != nil {
+				return nil, err
+			}
+			union, err = extendLimits(union, options.Stretch, stats)
+			if err != nil {
+				return nil, err
+			}
+		}
+		if union.Min > union.Max {
+			return nil, errors.New("None of the products has valid values in band " + band)
+		}
+		result[channels[i]] = union
+	}
+	return result, nil
+}
+
+// mosaicExpressionLimits computes the stretch limits of options.Expr over all products.
+// Without stretch mode the user supplied bounds are used, defaulting to the value range of normalized indices.
+func mosaicExpressionLimits(products []*Product, options options) (stretchLimits, error) {
+	if options.Stretch == "" {
+		if options.Greymin == 0 && options.Greymax == 0 {
+			return stretchLimits{-1, 1}, nil
+		}
+		return stretchLimits{options.Greymin, options.Greymax}, nil
+	}
+	expr, err := parseExpression(options.Expr)
+	if err != nil {
+		return stretchLimits{}, err
+	}
+	union := emptyLimits()
+	for _, p := range products {
+		data, _, err := ReadExpressionData(expr, p.Name)
+		if err != nil {
+			return stretchLimits{}, err
+		}
+		union, err = extendLimits(union, options.Stretch, statisticsFloat32(data, nil))
+		if err != nil {
+			return stretchLimits{}, err
+		}
+	}
+	if union.Min > union.Max {
+		return stretchLimits{}, errors.New("None of the products has valid values for the expression")
+	}
+	return union, nil
+}
+
+// emptyLimits returns limits replaced by the first extension
+func emptyLimits() stretchLimits {
+	return stretchLimits{math.Inf(1), math.Inf(-1)}
+}
+
+// extendLimits extends limits to span the stretch of given mode computed from stats. Statistics without valid values are ignored.
+func extendLimits(limits stretchLimits, mode string, stats *bandStatistics) (stretchLimits, error) {
+	if stats.Count == 0 {
+		return limits, nil
+	}
+	stretch, err := newContrastStretch(mode, stats)
+	if err != nil {
+		return limits, err
+	}
+	return stretchLimits{
+		math.Min(limits.Min, stretch.Limits.Min),
+		math.Max(limits.Max, stretch.Limits.Max),
+	}, nil
+}
+
+// newExpressionRenderer renders expr evaluated over the bands of dataset, stretched between limits and colored by palette if given
+func newExpressionRenderer(dataset string, expr *expression, limits stretchLimits, palette colormap) (*rasterRenderer, error) {
+	files := make([]string, len(expr.bands))
+	for i, band := range expr.bands {
+		var err error
+		files[i], err = bandLocation(dataset, band)
+		if err != nil {
+			return nil, err
+		}
+	}
+	var colors [256]color.RGBA
+	if palette != nil {
+		colors = palette.palette()
+	}
+	stretch := &contrastStretch{Limits: limits}
+	values := make([]float64, len(files))
+	colorize := func(dn []uint16) color.RGBA {
+		v := expr.evalDN(dn, values)
+		if math.IsNaN(v) {
+			return color.RGBA{}
+		}
+		c := stretch.apply(v)
+		if palette == nil {
+			return color.RGBA{c, c, c, 255}
+		}
+		return colors[c]
+	}
+	return newRasterRenderer(files, firstBands(len(files)), colorize)
+}
+
+// generateMosaic renders products into one pyramid in data/{id}/, the first product is drawn on top
+func generateMosaic(products []*Product, options options, job *Job) error {
+	// Apply the same value range to all products to avoid seams
+	var limits map[string]stretchLimits
+	if !options.TCI {
+		var err error
+		limits, err = mosaicLimits(products, options)
+		if err != nil {
+			return errors.New("Unable to compute stretch: " + err.Error())
+		}
+		options.Rcmin, options.Rcmax = limits["red"].Min, limits["red"].Max
+		options.Gcmin, options.Gcmax = limits["green"].Min, limits["green"].Max
+		options.Bcmin, options.Bcmax = limits["blue"].Min, limits["blue"].Max
+		options.Greymin, options.Greymax = limits["grey"].Min, limits["grey"].Max
+		Jobs.SetResult(job, limits)
+	}
+
+	var classes []int
+	if options.Mask != "" {
+		var err error
+		classes, err = parseMaskClasses(options.Mask)
+		if err != nil {
+			return err
+		}
+	}
+
+	var expr *expression
+	var palette colormap
+	if options.Expr != "" {
+		var err error
+		expr, err = parseExpression(options.Expr)
+		if err != nil {
+			return err
+		}
+		palette, err = optionalColormap(options.Cmap)
+		if err != nil {
+			return err
+		}
+	}
+
+	mosaic := &mosaicRenderer{}
+	defer mosaic.Close()
+	for _, p := range products {
+		var renderer *rasterRenderer
+		var err error
+		if expr != nil {
+			renderer, err = newExpressionRenderer(p.Name, expr, limits["grey"], palette)
+		} else {
+			renderer, err = newTileRenderer(p.Name, options)
+		}
+		if err != nil {
+			return errors.New("Unable to open Dataset " + p.Name + ": " + err.Error())
+		}
+		mosaic.renderers = append(mosaic.renderers, renderer)
+
+		// Let products below show through masked pixels
+		if options.Mask != "" {
+			location, err := bandLocation(p.Name, "SCL")
+			if err != nil {
+				return errors.New("Unable to mask Dataset " + p.Name + ", masking mosaics requires L2A products: " + err.Error())
+			}
+			err = renderer.addMask(location, classes)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	if Verbose {
+		fmt.Println("Tiling mosaic of " + strconv.Itoa(len(products)) + " products...")
+	}
+	err := writePyramid(mosaic, "data/"+options.id, MinZoom, MaxZoom, func(percent int) {
+		Jobs.SetProgress(job, percent)
+	})
+	if err != nil {
+		return errors.New("Unable to tile mosaic: " + err.Error())
+	}
+	return nil
+}
@@ -0,0 +1,31 @@
+package main
+
+import "testing"
+
+func TestExtendLimits(t *testing.T) {
+	products := []*bandStatistics{
+		{Count: 10, Min: 200, Max: 3000},
+		{Count: 0, Min: 0, Max: 0},
+		{Count: 5, Min: 100, Max: 2500},
+		{Count: 8, Min: 400, Max: 4200},
+	}
+	limits := emptyLimits()
+	for _, stats := range products {
+		var err error
+		limits, err = extendLimits(limits, StretchMinMax, stats)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if want := (stretchLimits{100, 4200}); limits != want {
+		t.Errorf("extendLimits = %v, want %v", limits, want)
+	}
+
+	limits, err := extendLimits(emptyLimits(), StretchMinMax, &bandStatistics{})
+	if err != nil || limits.Min <= limits.Max {
+		t.Errorf("extendLimits without valid values = %v, %v, want empty limits", limits, err)
+	}
+	if _, err := extendLimits(emptyLimits(), "unknown", products[0]); err == nil {
+		t.Error("extendLimits with unknown stretch succeeded, want error")
+	}
+}
@@ -0,0 +1,299 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"github.com/ling-js/go-gdal"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrBandNotFound is returned when a band cannot be found in a product
+var ErrBandNotFound = errors.New("Cannot find Band in Dataset")
+
+// productName returns the SAFE folder name of a product given either the folder name or a GDAL subdataset name
+func productName(name string) string {
+	// Subdatasets look like SENTINEL2_L1C:/opt/sentinel2/S2A_....SAFE/MTD_MSIL1C.xml:10m:EPSG_32632
+	if i := strings.Index(name, "/MTD_MSI"); i != -1 {
+		name = name[:i]
+		name = name[strings.LastIndex(name, "/")+1:]
+	}
+	return strings.TrimSuffix(name, "/")
+}
+
+// granuleLocation returns the location of the dynamically named granule folder of a product
+func granuleLocation(product string) (string, error) {
+	datalocation := DataSource + product + "/GRANULE/"
+	subfolder, err := ioutil.ReadDir(datalocation)
+	if err != nil {
+		return "", err
+	}
+	if len(subfolder) == 0 {
+		return "", errors.New("No granule found in Dataset " + product)
+	}
+	return datalocation + subfolder[0].Name() + "/", nil
+}
+
+// normalizeBandname converts band names like B4 to the B04 notation used in file names
+func normalizeBandname(band string) string {
+	band = strings.ToUpper(band)
+	if len(band) == 2 && band[0] == 'B' {
+		return "B0" + band[1:]
+	}
+	return band
+}
+
+// bandLocation returns the location of the jp2 file holding band of product.
+// band is either a L2A file name as listed by /search, or a band name like B04, B8A, TCI or SCL.
+// For band names the file with the best available resolution is returned.
+func bandLocation(product, band string) (string, error) {
+	granule, err := granuleLocation(productName(product))
+	if err != nil {
+		return "", err
+	}
+
+	// Resolve file names directly, L2A file names end in _{resolution}m.jp2
+	if strings.HasSuffix(band, ".jp2") {
+		if strings.HasSuffix(band, "m.jp2") && len(band) > 8 {
+			return granule + "IMG_DATA/R" + band[len(band)-7:len(band)-5] + "m/" + band, nil
+		}
+		return granule + "IMG_DATA/" + band, nil
+	}
+
+	// Search image folders from highest to lowest resolution
+	band = normalizeBandname(band)
+	for _, folder := range []string{"IMG_DATA/", "IMG_DATA/R10m/", "IMG_DATA/R20m/", "IMG_DATA/R60m/"} {
+		files, err := ioutil.ReadDir(granule + folder)
+		if err != nil {
+			continue
+		}
+		for _, file := range files {
+			name := file.Name()
+			if !strings.HasSuffix(name, ".jp2") {
+				continue
+			}
+			if strings.HasSuffix(name, "_"+band+".jp2") || strings.Contains(name, "_"+band+"_") {
+				return granule + folder + name, nil
+			}
+		}
+	}
+	return "", ErrBandNotFound
+}
+
+// productNamePattern matches SAFE folder names like S2A_MSIL1C_20170105T013442_N0204_R031_T53NMJ_20170105T013443.SAFE
+var productNamePattern = regexp.MustCompile(`^S2[A-Z]_MSIL(1C|2A)_[0-9]{8}T[0-9]{6}_[0-9A-Za-z_]+(\.SAFE)?$`)
+
+// Increase whenever fields of Product change to force reindexing
+const productVersion = 4
+
+// Product holds the indexed metadata of a single SAFE product
+type Product struct {
+	Version        int       `json:"version"`
+	Name           string    `json:"name"`
+	Level          string    `json:"level"`
+	GenerationTime time.Time `json:"generationTime"`
+	SensingTime    time.Time `json:"sensingTime"`
+	Footprint      string    `json:"footprint"`
+	// Cloud cover in percent, nil if unknown
+	CloudCover *float64 `json:"cloudCover"`
+	TileID     string   `json:"tileId"`
+	// Platform like S2A, relative orbit number and processing baseline like 02.06
+	Platform           string            `json:"platform"`
+	RelativeOrbit      int               `json:"relativeOrbit"`
+	ProcessingBaseline string            `json:"processingBaseline"`
+	Metadata           map[string]string `json:"metadata"`
+	// Granule folder relative to the product folder
+	Granule string `json:"granule"`
+	// jp2 files relative to the granule folder
+	Images []string `json:"images"`
+	// Modification time of the metadata file when indexed
+	Modified time.Time `json:"modified"`
+}
+
+// metadataFile returns the location and modification time of the metadata file of a product
+func metadataFile(name string) (string, time.Time, error) {
+	for _, file := range []string{"/MTD_MSIL1C.xml", "/MTD_MSIL2A.xml"} {
+		info, err := os.Stat(DataSource + name + file)
+		if err == nil {
+			return DataSource + name + file, info.ModTime(), nil
+		}
+	}
+	return "", time.Time{}, errors.New("No MTD_MSIL1C.xml or MTD_MSIL2A.xml found")
+}
+
+// readProduct reads the metadata of a product from DataSource
+func readProduct(name string) (*Product, error) {
+	if !productNamePattern.MatchString(name) {
+		return nil, errors.New("Invalid product name " + name)
+	}
+	location, modified, err := metadataFile(name)
+	if err != nil {
+		return nil, err
+	}
+	p := &Product{
+		Version:  productVersion,
+		Name:     name,
+		Modified: modified,
+		Metadata: make(map[string]string),
+	}
+
+	// Read Metadata via GDAL
+	dataset, err := gdal.Open(location, gdal.ReadOnly)
+	if err != nil {
+		return nil, err
+	}
+	metadata := dataset.Metadata("")
+	if strings.HasSuffix(location, "L1C.xml") {
+		p.Level = "L1C"
+		metadata = append(metadata, dataset.Metadata("Subdatasets")...)
+	} else {
+		p.Level = "L2A"
+	}
+	dataset.Close()
+	for _, item := range metadata {
+		keyValuePair := strings.SplitN(item, "=", 2)
+		if len(keyValuePair) == 2 {
+			p.Metadata[keyValuePair[0]] = keyValuePair[1]
+		}
+	}
+
+	// Get typed Metadata
+	generationTimeRAW, footprintRAW, err := getMetadataItems(metadata)
+	if err != nil {
+		return nil, err
+	}
+	p.GenerationTime, err = time.Parse(time.RFC3339, generationTimeRAW)
+	if err != nil {
+		return nil, err
+	}
+	p.Footprint = footprintRAW
+	p.SensingTime = p.GenerationTime
+	if sensingTime, err := time.Parse(time.RFC3339, p.Metadata["PRODUCT_START_TIME"]); err == nil {
+		p.SensingTime = sensingTime
+	}
+	for _, key := range []string{"CLOUDY_PIXEL_PERCENTAGE", "CLOUD_COVERAGE_ASSESSMENT"} {
+		cloudcover, err := strconv.ParseFloat(p.Metadata[key], 64)
+		if err == nil {
+			p.CloudCover = &cloudcover
+			break
+		}
+	}
+
+	// Get fields encoded in product name like S2A_MSIL1C_20170105T013442_N0204_R031_T53NMJ_20170105T013443.SAFE
+	for _, part := range strings.Split(strings.TrimSuffix(name, ".SAFE"), "_") {
+		switch {
+		case len(part) == 6 && part[0] == 'T':
+			p.TileID = part[1:]
+		case len(part) == 4 && part[0] == 'R':
+			if orbit, err := strconv.Atoi(part[1:]); err == nil {
+				p.RelativeOrbit = orbit
+			}
+		case len(part) == 5 && part[0] == 'N':
+			if baseline, err := normalizeBaseline(part); err == nil {
+				p.ProcessingBaseline = baseline
+			}
+		}
+	}
+	if baseline, err := normalizeBaseline(p.Metadata["PROCESSING_BASELINE"]); err == nil {
+		p.ProcessingBaseline = baseline
+	}
+	p.Platform, _ = normalizePlatform(name[:3])
+	if platform, err := normalizePlatform(p.Metadata["DATATAKE_1_SPACECRAFT_NAME"]); err == nil {
+		p.Platform = platform
+	}
+
+	// List all image files
+	granule, err := granuleLocation(name)
+	if err != nil {
+		return nil, err
+	}
+	p.Granule = strings.TrimPrefix(granule, DataSource+name+"/")
+	p.Images, err = listImages(granule, "IMG_DATA/")
+	if err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// normalizeBaseline converts processing baselines like N0206, 0206 or 2.06 to the 02.06 notation used in metadata
+func normalizeBaseline(baseline string) (string, error) {
+	baseline = strings.TrimPrefix(strings.ToUpper(strings.TrimSpace(baseline)), "N")
+	var major, minor string
+	if i := strings.Index(baseline, "."); i != -1 {
+		major, minor = baseline[:i], baseline[i+1:]
+	} else if len(baseline) == 4 {
+		major, minor = baseline[:2], baseline[2:]
+	}
+	majorNumber, err := strconv.Atoi(major)
+	if err != nil {
+		return "", errors.New("Invalid processing baseline '" + baseline + "'")
+	}
+	minorNumber, err := strconv.Atoi(minor)
+	if err != nil {
+		return "", errors.New("Invalid processing baseline '" + baseline + "'")
+	}
+	return fmt.Sprintf("%02d.%02d", majorNumber, minorNumber), nil
+}
+
+// normalizePlatform converts platforms like Sentinel-2A, 2a or s2a to the S2A notation used in product names
+func normalizePlatform(platform string) (string, error) {
+	platform = strings.TrimPrefix(strings.ToUpper(strings.TrimSpace(platform)), "SENTINEL-")
+	platform = strings.TrimPrefix(platform, "S")
+	if len(platform) != 2 || platform[0] != '2' || platform[1] < 'A' || platform[1] > 'Z' {
+		return "", errors.New("Invalid platform '" + platform + "'")
+	}
+	return "S" + platform, nil
+}
+
+// listImages lists all jp2 files in folder of granule and its subfolders
+func listImages(granule, folder string) ([]string, error) {
+	files, err := ioutil.ReadDir(granule + folder)
+	if err != nil {
+		return nil, err
+	}
+	var images []string
+	for _, file := range files {
+		if file.IsDir() {
+			subimages, err := listImages(granule, folder+file.Name()+"/")
+			if err != nil {
+				return nil, err
+			}
+			images = append(images, subimages...)
+		} else if strings.HasSuffix(file.Name(), ".jp2") {
+			images = append(images, folder+file.Name())
+		}
+	}
+	return images, nil
+}
+
+// imagesIn returns the file names of all images of p in given subfolder of IMG_DATA
+func (p *Product) imagesIn(subfolder string) []string {
+	var names []string
+	prefix := "IMG_DATA/" + subfolder
+	for _, image := range p.Images {
+		if strings.HasPrefix(image, prefix) && !strings.Contains(image[len(prefix):], "/") {
+			names = append(names, image[len(prefix):])
+		}
+	}
+	return names
+}
+
+// bands returns the names of all bands of p like B04 or TCI, ignoring resolutions
+func (p *Product) bands() []string {
+	bands := []string{}
+	found := make(map[string]bool)
+	for _, image := range p.Images {
+		band := bandOfFile(image)
+		if !found[band] {
+			found[band] = true
+			bands = append(bands, band)
+		}
+	}
+	sort.Strings(bands)
+	return bands
+}
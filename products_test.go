@@ -0,0 +1,52 @@
+package main
+
+import (
+	"sort"
+	"testing"
+)
+
+func TestProductNamePattern(t *testing.T) {
+	for _, name := range []string{
+		"S2A_MSIL1C_20170105T013442_N0204_R031_T53NMJ_20170105T013443.SAFE",
+		"S2B_MSIL2A_20180505T103019_N0207_R108_T32ULC_20180505T133317.SAFE",
+		"S2B_MSIL2A_20180505T103019_N0207_R108_T32ULC_20180505T133317",
+	} {
+		if !productNamePattern.MatchString(name) {
+			t.Errorf("productNamePattern does not match %q", name)
+		}
+	}
+	for _, name := range []string{
+		"",
+		"x",
+		"MTD",
+		"S2A_MSIL1C",
+		"S2A_MSIL3A_20170105T013442_N0204_R031_T53NMJ_20170105T013443.SAFE",
+		"S2A_OPER_PRD_MSIL1C_PDMC_20160101T000000_R031_V20160101T000000_20160101T000000.SAFE",
+		"../S2A_MSIL1C_20170105T013442_N0204_R031_T53NMJ_20170105T013443.SAFE",
+	} {
+		if productNamePattern.MatchString(name) {
+			t.Errorf("productNamePattern matches %q", name)
+		}
+	}
+}
+
+func TestReadProductRejectsInvalidNames(t *testing.T) {
+	for _, name := range []string{"", "x", "lost+found"} {
+		if _, err := readProduct(name); err == nil {
+			t.Errorf("readProduct(%q) succeeded, want error", name)
+		}
+	}
+}
+
+func TestSentinel2DatasetShortNames(t *testing.T) {
+	products := Sentinel2Dataset{
+		{Name: "x"},
+		{Name: "S2A_MSIL1C_20170105T013442_N0204_R031_T53NMJ_20170105T013443.SAFE"},
+		{Name: "S2B_MSIL2A_20180505T103019_N0207_R108_T32ULC_20180505T133317.SAFE"},
+		{Name: ""},
+	}
+	sort.Sort(products)
+	if products[0].Name != "x" || products[1].Name[:3] != "S2B" || products[2].Name[:3] != "S2A" || products[3].Name != "" {
+		t.Errorf("sorted products = %v", names(products))
+	}
+}
@@ -0,0 +1,256 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/ling-js/go-gdal"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Default distance between samples of a profile in meters
+const profileDefaultSpacing = 10.0
+
+// Maximum number of samples of a single profile
+const profileMaxSamples = 10000
+
+// Request Schema of /profile
+type profileRequest struct {
+	Dataset string `json:"dataset"`
+	// Band name, band math expression or name of a built-in index
+	Band string `json:"band"`
+	// GeoJSON LineString or Feature in WGS84
+	Geometry json.RawMessage `json:"geometry"`
+	// Distance between samples in meters, defaults to 10
+	Spacing float64 `json:"spacing"`
+}
+
+// Response Schema of /profile
+type profileResponse struct {
+	Dataset string          `json:"dataset"`
+	Band    string          `json:"band"`
+	Spacing float64         `json:"spacing"`
+	Length  float64         `json:"length"`
+	Samples []profileSample `json:"samples"`
+}
+
+type profileSample struct {
+	// Distance from the start of the line in meters
+	Distance float64 `json:"distance"`
+	Lon      float64 `json:"lon"`
+	Lat      float64 `json:"lat"`
+	// Reflectance or index value, nil for nodata or outside of the Dataset
+	Value *float64 `json:"value"`
+}
+
+// ProfileHandler returns the values of a band or index of a Dataset sampled along a line
+func ProfileHandler(w http.ResponseWriter, r *http.Request) {
+	defer Timetrack(time.Now(), "Profile ")
+
+	var request profileRequest
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		w.WriteHeader(400)
+		w.Write([]byte("Unable to parse request body: " + err.Error()))
+		return
+	}
+
+	// Log request if verbose is set
+	if Verbose {
+		fmt.Println("Request to /profile for " + request.Dataset + " and band " + request.Band)
+	}
+
+	if request.Dataset == "" || request.Band == "" || request.Geometry == nil {
+		w.WriteHeader(400)
+		w.Write([]byte("Fields 'dataset', 'band' and 'geometry' are required"))
+		return
+	}
+	if request.Spacing == 0 {
+		request.Spacing = profileDefaultSpacing
+	}
+	if request.Spacing < 0 || math.IsNaN(request.Spacing) {
+		w.WriteHeader(400)
+		w.Write([]byte("Field 'spacing' must be positive"))
+		return
+	}
+	expr, err := parseExpression(request.Band)
+	if err != nil {
+		w.WriteHeader(400)
+		w.Write([]byte("Invalid band or expression: " + err.Error()))
+		return
+	}
+	line, err := parseLineString(request.Geometry)
+	if err != nil {
+		w.WriteHeader(400)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	// Check that all bands exist before reading them
+	err = checkBands(request.Dataset, expr)
+	if err != nil {
+		w.WriteHeader(404)
+		w.Write([]byte(err.Error()))
+		return
+	}
+	locations := make([]string, len(expr.bands))
+	for i, band := range expr.bands {
+		locations[i], _ = bandLocation(request.Dataset, band)
+	}
+
+	// Sample the line in the CRS of the Dataset, all bands of a product share the same UTM zone
+	grid, err := openRasterGrid(locations[0])
+	if err != nil {
+		w.WriteHeader(500)
+		w.Write([]byte("Unable to read Dataset: " + err.Error()))
+		return
+	}
+	defer grid.Close()
+	x, y, distances, length, err := sampleLine(grid.projection, line, request.Spacing)
+	if err != nil {
+		w.WriteHeader(400)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	// Read all referenced bands at the samples
+	dn := make([][]uint16, len(locations))
+	for i, location := range locations {
+		dn[i], err = readPoints(location, x, y)
+		if err != nil {
+			w.WriteHeader(500)
+			w.Write([]byte("Error reading Band " + expr.bands[i] + ": " + err.Error()))
+			return
+		}
+	}
+
+	// Transform samples back to lon/lat
+	lon := append([]float64(nil), x...)
+	lat := append([]float64(nil), y...)
+	grid.projection.inverse(lon, lat)
+
+	response := profileResponse{
+		Dataset: productName(request.Dataset),
+		Band:    request.Band,
+		Spacing: request.Spacing,
+		Length:  length,
+		Samples: []profileSample{},
+	}
+	sample := make([]uint16, len(dn))
+	values := make([]float64, len(dn))
+	for i := range x {
+		for j := range dn {
+			sample[j] = dn[j][i]
+		}
+		result := profileSample{Distance: distances[i], Lon: lon[i], Lat: lat[i]}
+		value := expr.evalDN(sample, values)
+		if !math.IsNaN(value) {
+			result.Value = &value
+		}
+		response.Samples = append(response.Samples, result)
+	}
+	writeJSON(w, "application/json", response)
+}
+
+// parseLineString parses a GeoJSON LineString or a Feature containing one into lon/lat positions
+func parseLineString(raw json.RawMessage) ([][]float64, error) {
+	features, err := parseFeatures(raw)
+	if err != nil {
+		return nil, err
+	}
+	if len(features) != 1 || features[0].Geometry == nil || features[0].Geometry.Type != "LineString" {
+		return nil, errors.New("Geometry must be a single LineString")
+	}
+	var line [][]float64
+	err = json.Unmarshal(features[0].Geometry.Coordinates, &line)
+	if err != nil {
+		return nil, errors.New("Invalid LineString: " + err.Error())
+	}
+	if len(line) < 2 {
+		return nil, errors.New("LineString needs at least two positions")
+	}
+	for _, position := range line {
+		if len(position) < 2 {
+			return nil, errors.New("GeoJSON positions need at least two coordinates")
+		}
+	}
+	return line, nil
+}
+
+// sampleLine transforms a lon/lat line into the CRS of p and samples it with samplePolyline
+func sampleLine(p *projection, line [][]float64, spacing float64) (x, y, distances []float64, length float64, err error) {
+	vx := make([]float64, len(line))
+	vy := make([]float64, len(line))
+	for i, position := range line {
+		vx[i], vy[i] = position[0], position[1]
+	}
+	p.forward(vx, vy)
+	for i := range vx {
+		if math.IsInf(vx[i], 0) {
+			return nil, nil, nil, 0, errors.New("LineString cannot be transformed into the CRS of the Dataset")
+		}
+	}
+	return samplePolyline(vx, vy, spacing)
+}
+
+// samplePolyline places samples every spacing units along the polyline vx/vy, always including the end of the line.
+// Returns the samples, their distance from the start and the length of the line.
+func samplePolyline(vx, vy []float64, spacing float64) (x, y, distances []float64, length float64, err error) {
+	for i := 1; i < len(vx); i++ {
+		length += math.Hypot(vx[i]-vx[i-1], vy[i]-vy[i-1])
+	}
+	if length/spacing+1 > profileMaxSamples {
+		return nil, nil, nil, 0, errors.New("Profile exceeds " + strconv.Itoa(profileMaxSamples) + " samples, increase 'spacing'")
+	}
+
+	// Walk along the segments, carrying the distance to the next sample over vertices
+	segmentStart := 0.0
+	next := 0.0
+	for i := 1; i < len(vx); i++ {
+		segment := math.Hypot(vx[i]-vx[i-1], vy[i]-vy[i-1])
+		for ; segment > 0 && next < segmentStart+segment; next += spacing {
+			t := (next - segmentStart) / segment
+			x = append(x, vx[i-1]+t*(vx[i]-vx[i-1]))
+			y = append(y, vy[i-1]+t*(vy[i]-vy[i-1]))
+			distances = append(distances, next)
+		}
+		segmentStart += segment
+	}
+	x = append(x, vx[len(vx)-1])
+	y = append(y, vy[len(vy)-1])
+	distances = append(distances, length)
+	return x, y, distances, length, nil
+}
+
+// readPoints reads the first band of the raster at location at all points given in its CRS.
+// Points outside of the raster are returned as nodata.
+func readPoints(location string, x, y []float64) ([]uint16, error) {
+	dataset, err := gdal.Open(location, gdal.ReadOnly)
+	if err != nil {
+		return nil, errors.New("Error opening Dataset: " + err.Error())
+	}
+	defer dataset.Close()
+	inverse, err := invertGeoTransform(dataset.GeoTransform())
+	if err != nil {
+		return nil, err
+	}
+
+	values := make([]uint16, len(x))
+	value := make([]uint16, 1)
+	for i := range x {
+		col, row := applyGeoTransform(inverse, x[i], y[i])
+		pixel, line := int(math.Floor(col)), int(math.Floor(row))
+		if col < 0 || row < 0 || pixel >= dataset.RasterXSize() || line >= dataset.RasterYSize() {
+			continue
+		}
+		err = dataset.IO(gdal.Read, pixel, line, 1, 1, value, 1, 1, 1, []int{1}, 0, 0, 0)
+		if err != nil {
+			return nil, errors.New("Error reading data from Dataset: " + err.Error())
+		}
+		values[i] = value[0]
+	}
+	return values, nil
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestParseLineString(t *testing.T) {
+	tests := []struct {
+		raw  string
+		want [][]float64
+	}{
+		{`{"type":"LineString","coordinates":[[7.6,51.9],[7.7,52]]}`, [][]float64{{7.6, 51.9}, {7.7, 52}}},
+		{`{"type":"Feature","geometry":{"type":"LineString","coordinates":[[7.6,51.9],[7.7,52],[7.8,52.1,60]]}}`, [][]float64{{7.6, 51.9}, {7.7, 52}, {7.8, 52.1, 60}}},
+	}
+	for _, test := range tests {
+		got, err := parseLineString(json.RawMessage(test.raw))
+		if err != nil || !reflect.DeepEqual(got, test.want) {
+			t.Errorf("parseLineString(%s) = %v, %v, want %v", test.raw, got, err, test.want)
+		}
+	}
+	for _, raw := range []string{
+		`{"type":"Point","coordinates":[7.6,51.9]}`,
+		`{"type":"LineString","coordinates":[[7.6,51.9]]}`,
+		`{"type":"LineString","coordinates":[[7.6,51.9],[7.7]]}`,
+		`{"type":"FeatureCollection","features":[]}`,
+		`{"type":"Feature","geometry":null}`,
+		`[`,
+	} {
+		if _, err := parseLineString(json.RawMessage(raw)); err == nil {
+			t.Errorf("parseLineString(%s) succeeded, want error", raw)
+		}
+	}
+}
+
+func TestSamplePolyline(t *testing.T) {
+	tests := []struct {
+		name      string
+		vx, vy    []float64
+		spacing   float64
+		x, y      []float64
+		distances []float64
+		length    float64
+	}{
+		{"exact", []float64{0, 20}, []float64{0, 0}, 10,
+			[]float64{0, 10, 20}, []float64{0, 0, 0}, []float64{0, 10, 20}, 20},
+		{"remainder", []float64{0, 0}, []float64{0, 25}, 10,
+			[]float64{0, 0, 0, 0}, []float64{0, 10, 20, 25}, []float64{0, 10, 20, 25}, 25},
+		{"carry over vertex", []float64{0, 15, 15}, []float64{0, 0, 15}, 10,
+			[]float64{0, 10, 15, 15}, []float64{0, 0, 5, 15}, []float64{0, 10, 20, 30}, 30},
+		{"duplicate vertex", []float64{0, 0, 10}, []float64{0, 0, 0}, 10,
+			[]float64{0, 10}, []float64{0, 0}, []float64{0, 10}, 10},
+		{"spacing longer than line", []float64{0, 3}, []float64{0, 4}, 10,
+			[]float64{0, 3}, []float64{0, 4}, []float64{0, 5}, 5},
+	}
+	for _, test := range tests {
+		x, y, distances, length, err := samplePolyline(test.vx, test.vy, test.spacing)
+		if err != nil {
+			t.Errorf("%s: samplePolyline failed: %v", test.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(x, test.x) || !reflect.DeepEqual(y, test.y) {
+			t.Errorf("%s: samples = %v/%v, want %v/%v", test.name, x, y, test.x, test.y)
+		}
+		if !reflect.DeepEqual(distances, test.distances) || length != test.length {
+			t.Errorf("%s: distances = %v, length %v, want %v, length %v", test.name, distances, length, test.distances, test.length)
+		}
+	}
+
+	if _, _, _, _, err := samplePolyline([]float64{0, profileMaxSamples * 10}, []float64{0, 0}, 10); err == nil {
+		t.Error("samplePolyline exceeding the sample limit succeeded, want error")
+	}
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"errors"
+	"github.com/ling-js/go-gdal"
+	"github.com/paulsmith/gogeos/geos"
+	"math"
+	"sort"
+)
+
+// rasterizePolygon calls set for every pixel of a width*height grid whose center lies inside the polygon.
+// rings are given in pixel coordinates, the first ring is the exterior and all others are holes.
+func rasterizePolygon(rings [][][2]float64, width, height int, set func(x, y int)) {
+	// Get rows covered by polygon
+	miny, maxy := math.Inf(1), math.Inf(-1)
+	for _, ring := range rings {
+		for _, p := range ring {
+			miny = math.Min(miny, p[1])
+			maxy = math.Max(maxy, p[1])
+		}
+	}
+	y0 := int(math.Max(0, math.Floor(miny)))
+	y1 := int(math.Min(float64(height), math.Ceil(maxy)))
+
+	var crossings []float64
+	for y := y0; y < y1; y++ {
+		center := float64(y) + 0.5
+
+		// Get intersections of the scanline with all edges
+		crossings = crossings[:0]
+		for _, ring := range rings {
+			for i := range ring {
+				a, b := ring[i], ring[(i+1)%len(ring)]
+				if (a[1] <= center) == (b[1] <= center) {
+					continue
+				}
+				crossings = append(crossings, a[0]+(center-a[1])/(b[1]-a[1])*(b[0]-a[0]))
+			}
+		}
+		sort.Float64s(crossings)
+
+		// Fill between pairs of intersections (even-odd rule)
+		for i := 0; i+1 < len(crossings); i += 2 {
+			x0 := int(math.Max(0, math.Ceil(crossings[i]-0.5)))
+			x1 := int(math.Min(float64(width), math.Ceil(crossings[i+1]-0.5)))
+			for x := x0; x < x1; x++ {
+				set(x, y)
+			}
+		}
+	}
+}
+
+// rasterGrid is the georeference of a raster used to convert lon/lat geometries to pixel coordinates
+type rasterGrid struct {
+	projection    *projection
+	inverse       [6]float64
+	width, height int
+}
+
+// openRasterGrid reads the georeference of the raster at location
+func openRasterGrid(location string) (*rasterGrid, error) {
+	dataset, err := gdal.Open(location, gdal.ReadOnly)
+	if err != nil {
+		return nil, errors.New("Error opening Dataset: " + err.Error())
+	}
+	defer dataset.Close()
+	g := &rasterGrid{width: dataset.RasterXSize(), height: dataset.RasterYSize()}
+	g.inverse, err = invertGeoTransform(dataset.GeoTransform())
+	if err != nil {
+		return nil, err
+	}
+	g.projection, err = newProjection(dataset.ProjectionRef())
+	if err != nil {
+		return nil, err
+	}
+	return g, nil
+}
+
+// Close frees all resources held by the grid
+func (g *rasterGrid) Close() {
+	g.projection.Close()
+}
+
+// crop restricts the grid to window w, so that pixel coordinates are relative to the window
+func (g *rasterGrid) crop(w zoneWindow) {
+	g.inverse[0] -= float64(w.x0)
+	g.inverse[3] -= float64(w.y0)
+	g.width, g.height = w.size()
+}
+
+// toPixel transforms lon/lat coordinates in place into pixel/line coordinates.
+// Points that cannot be transformed are set to +Inf.
+func (g *rasterGrid) toPixel(x, y []float64) {
+	g.projection.forward(x, y)
+	for i := range x {
+		if !math.IsInf(x[i], 0) {
+			x[i], y[i] = applyGeoTransform(g.inverse, x[i], y[i])
+		}
+	}
+}
+
+// polygonPixels returns the indices y*width+x of all pixels whose center lies inside a lon/lat polygon or multipolygon
+func (g *rasterGrid) polygonPixels(geometry *geos.Geometry) ([]int, error) {
+	polygons, err := polygonsOf(geometry)
+	if err != nil {
+		return nil, err
+	}
+	var pixels []int
+	for _, polygon := range polygons {
+		for _, ring := range polygon {
+			x := make([]float64, len(ring))
+			y := make([]float64, len(ring))
+			for i := range ring {
+				x[i], y[i] = ring[i][0], ring[i][1]
+			}
+			g.toPixel(x, y)
+			for i := range ring {
+				if math.IsInf(x[i], 0) {
+					return nil, errors.New("Polygon cannot be transformed into the CRS of the Dataset")
+				}
+				ring[i] = [2]float64{x[i], y[i]}
+			}
+		}
+		rasterizePolygon(polygon, g.width, g.height, func(x, y int) {
+			pixels = append(pixels, y*g.width+x)
+		})
+	}
+
+	// Pixels covered by overlapping polygons are only counted once
+	if len(polygons) > 1 {
+		sort.Ints(pixels)
+		unique := pixels[:0]
+		for i, pixel := range pixels {
+			if i == 0 || pixel != pixels[i-1] {
+				unique = append(unique, pixel)
+			}
+		}
+		pixels = unique
+	}
+	return pixels, nil
+}
+
+// polygonsOf returns the rings of all polygons of a polygon or multipolygon
+func polygonsOf(geometry *geos.Geometry) ([][][][2]float64, error) {
+	geometryType, err := geometry.Type()
+	if err != nil {
+		return nil, err
+	}
+	switch geometryType {
+	case geos.POLYGON:
+		rings, err := polygonPositions(geometry)
+		if err != nil {
+			return nil, err
+		}
+		return [][][][2]float64{rings}, nil
+	case geos.MULTIPOLYGON:
+		n, err := geometry.NGeometry()
+		if err != nil {
+			return nil, err
+		}
+		var polygons [][][][2]float64
+		for i := 0; i < n; i++ {
+			member, err := geometry.Geometry(i)
+			if err != nil {
+				return nil, err
+			}
+			rings, err := polygonPositions(member)
+			if err != nil {
+				return nil, err
+			}
+			polygons = append(polygons, rings)
+		}
+		return polygons, nil
+	}
+	return nil, errors.New("Only Polygons and MultiPolygons are supported")
+}
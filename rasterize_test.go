@@ -0,0 +1,47 @@
+package main
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestRasterizePolygon(t *testing.T) {
+	square := [][2]float64{{1, 1}, {4, 1}, {4, 4}, {1, 4}}
+	tests := []struct {
+		name  string
+		rings [][][2]float64
+		want  []int
+	}{
+		{"square", [][][2]float64{square}, []int{
+			6, 7, 8,
+			11, 12, 13,
+			16, 17, 18,
+		}},
+		{"hole", [][][2]float64{square, {{2, 2}, {3, 2}, {3, 3}, {2, 3}}}, []int{
+			6, 7, 8,
+			11, 13,
+			16, 17, 18,
+		}},
+		{"triangle", [][][2]float64{{{0, 0}, {5, 0}, {0, 5}}}, []int{
+			0, 1, 2, 3,
+			5, 6, 7,
+			10, 11,
+			15,
+		}},
+		{"clipped", [][][2]float64{{{-2, -2}, {2, -2}, {2, 2}, {-2, 2}}}, []int{0, 1, 5, 6}},
+		{"outside", [][][2]float64{{{6, 6}, {8, 6}, {8, 8}}}, nil},
+		// Polygons smaller than a pixel do not contain any pixel center
+		{"tiny", [][][2]float64{{{2.1, 2.1}, {2.4, 2.1}, {2.4, 2.4}}}, nil},
+	}
+	for _, test := range tests {
+		var got []int
+		rasterizePolygon(test.rings, 5, 5, func(x, y int) {
+			got = append(got, y*5+x)
+		})
+		sort.Ints(got)
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: pixels = %v, want %v", test.name, got, test.want)
+		}
+	}
+}
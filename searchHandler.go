@@ -1,14 +1,13 @@
 package main
 
 import (
+	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
-	"github.com/ling-js/go-gdal"
 	"github.com/paulsmith/gogeos/geos"
-	"io/ioutil"
 	"net/http"
-	"os"
+	"net/url"
 	"regexp"
 	"sort"
 	"strconv"
@@ -16,14 +15,32 @@ import (
 	"time"
 )
 
-// Sentinel2Dataset provieds custom Interface to os.FileInfo to sort by Date
-type Sentinel2Dataset []os.FileInfo
+// Default and maximum number of products per page
+const (
+	searchDefaultLimit = 8
+	searchMaxLimit     = 100
+)
+
+// Sentinel2Dataset provieds custom Interface to sort Products by Date
+type Sentinel2Dataset []*Product
 
 func (nf Sentinel2Dataset) Len() int      { return len(nf) }
 func (nf Sentinel2Dataset) Swap(i, j int) { nf[i], nf[j] = nf[j], nf[i] }
 func (nf Sentinel2Dataset) Less(i, j int) bool {
-	// Compare names from 12th letter onwards lexicographically
-	return nf[i].Name()[11:] > nf[j].Name()[11:]
+	// Compare names from 12th letter onwards lexicographically, full names break ties
+	a, b := datasetSortKey(nf[i].Name), datasetSortKey(nf[j].Name)
+	if a != b {
+		return a > b
+	}
+	return nf[i].Name > nf[j].Name
+}
+
+// datasetSortKey returns the part of a product name starting with its sensing time, or the whole name if it is shorter
+func datasetSortKey(name string) string {
+	if len(name) < 11 {
+		return name
+	}
+	return name[11:]
 }
 
 // SearchHandler returns all Datasets not matching one of the filter criteria.
@@ -36,47 +53,30 @@ func SearchHandler(w http.ResponseWriter, r *http.Request) {
 		fmt.Print("Request to /search with parameters: ")
 		fmt.Println(q)
 	}
-	// Get all Datasets from Directory
-	datasets, err := ioutil.ReadDir(DataSource)
+	// Get all Datasets from Index
+	datasets, err := Index.All()
 	if err != nil {
 		w.WriteHeader(500)
-		w.Write([]byte("Unable to open Data Repository: " + err.Error()))
+		w.Write([]byte("Unable to read Index: " + err.Error()))
 		return
 	}
-	// Sort by Date
-	sort.Sort(Sentinel2Dataset(datasets))
-
-	bboxstring := q.Get("bbox")
-	var bbox *geos.Geometry
-
-	// Parse supplied coordinates into bbox
-	if bboxstring != "" {
-		coordinates := strings.Split(bboxstring, ",")
-		polygon :=
-			"POLYGON((" +
-				coordinates[0] + " " +
-				coordinates[1] + "," +
-				coordinates[0] + " " +
-				coordinates[3] + "," +
-				coordinates[2] + " " +
-				coordinates[3] + "," +
-				coordinates[2] + " " +
-				coordinates[1] + "," +
-				coordinates[0] + " " +
-				coordinates[1] + "))"
-
-		bbox, err = geos.FromWKT(polygon)
-		if err != nil {
-			w.WriteHeader(400)
-			if Verbose {
-				fmt.Println(err)
-			}
-			w.Write([]byte(err.Error()))
-			return
-		}
+	// Sort by requested field
+	before, err := sortProducts(datasets, q.Get("sort"), q.Get("order"))
+	if err != nil {
+		w.WriteHeader(400)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	// Parse area of interest from bbox or intersects
+	spatial, err := parseSpatialFilter(q)
+	if err != nil {
+		w.WriteHeader(400)
 		if Verbose {
-			fmt.Println("Parsed bbox from request as: " + bbox.String())
+			fmt.Println(err)
 		}
+		w.Write([]byte(err.Error()))
+		return
 	}
 
 	// Get Filter Filter by Name
@@ -87,15 +87,22 @@ func SearchHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Setup filter by bbox, startDate, endDate
+	// Filter by cloud cover and product properties
+	err = propertiesFilter(datasets, q)
+	if err != nil {
+		w.WriteHeader(400)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	// Setup filter by area, startDate, endDate
 	startDate := q.Get("startdate")
 	endDate := q.Get("enddate")
 	filterDates := startDate != "" && endDate != ""
-	filterBox := bbox != nil
 
 	// Only Filter if filters are supplied
-	if filterDates || filterBox {
-		err = metaDataFilter(datasets, q.Get("startdate"), q.Get("enddate"), bbox, filterDates, filterBox)
+	if filterDates || spatial != nil {
+		err = metaDataFilter(datasets, q.Get("startdate"), q.Get("enddate"), spatial, filterDates)
 		if err != nil {
 			w.WriteHeader(500)
 			w.Write([]byte("Unable to filter by metadata: " + err.Error()))
@@ -108,46 +115,81 @@ func SearchHandler(w http.ResponseWriter, r *http.Request) {
 	page := 0
 	if pagestring != "" {
 		page, err = strconv.Atoi(pagestring)
+		if err != nil || page < 0 {
+			w.WriteHeader(400)
+			w.Write([]byte("Unable to get page parameter from URL"))
+			return
+		}
+	}
+
+	// Get page size from URL
+	limit := searchDefaultLimit
+	if q.Get("limit") != "" {
+		limit, err = strconv.Atoi(q.Get("limit"))
+		if err != nil || limit < 1 || limit > searchMaxLimit {
+			w.WriteHeader(400)
+			w.Write([]byte("limit must be between 1 and " + strconv.Itoa(searchMaxLimit)))
+			return
+		}
+	}
+
+	// Get cursor from URL, cursors take precedence over pages
+	var cursor *searchCursor
+	if q.Get("cursor") != "" {
+		cursor, err = parseSearchCursor(q.Get("cursor"))
 		if err != nil {
 			w.WriteHeader(400)
-			w.Write([]byte("Unable to get page parameter from URL: " + err.Error()))
+			w.Write([]byte(err.Error()))
 			return
 		}
 	}
 
-	// Prepare metadata output
-	var metadatajson []byte
+	// Get requested products
+	results, prev, next, totalcounter := searchPage(datasets, page, limit, cursor, before)
+
+	// Write total count and links to neighbouring pages into response Headers
+	w.Header().Set("X-Dataset-Count", strconv.Itoa(totalcounter))
+	w.Header().Set("Access-Control-Expose-Headers", "X-Dataset-Count, Link")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	var links []string
+	if prev != "" {
+		links = append(links, "<"+searchLink(r, prev)+">; rel=\"prev\"")
+	}
+	if next != "" {
+		links = append(links, "<"+searchLink(r, next)+">; rel=\"next\"")
+	}
+	if len(links) > 0 {
+		w.Header().Set("Link", strings.Join(links, ", "))
+	}
 
-	// Get metadata
-	mtdL1C, mtdL2A, totalcounter, err := getMetaData(datasets, page)
+	// Create response in requested format
+	var response interface{}
+	contentType := "application/json"
+	switch q.Get("format") {
+	case "", "json":
+		response, err = newSearchResponse(results, prev, next)
+	case "geojson":
+		response, err = newSearchFeatureCollection(results, prev, next)
+		contentType = "application/geo+json"
+	default:
+		w.WriteHeader(400)
+		w.Write([]byte("Unknown format '" + q.Get("format") + "', use json or geojson"))
+		return
+	}
 	if err != nil {
 		w.WriteHeader(500)
 		w.Write([]byte("Unable to retrieve Metadata " + err.Error()))
 		return
 	}
 
-	// Write max page into response Headers
-	w.Header().Set("X-Dataset-Count", strconv.Itoa(totalcounter))
-	w.Header().Set("Access-Control-Expose-Headers", "X-Dataset-Count")
-
-	// Set content-type header
-	w.Header().Set("Content-Type", "application/json")
-
-	// Create metadata
-	metadatajson = append(metadatajson, mtdL1C[:len(mtdL1C)-1]...)
-	metadatajson = append(metadatajson, []byte("],")...)
-	metadatajson = append(metadatajson, mtdL2A[:len(mtdL2A)-1]...)
-	metadatajson = append(metadatajson, []byte("]}")...)
-
 	// Write Response with default 200 OK Status Code
-	w.Header().Set("Access-Control-Allow-Origin", "*")
-	w.Write(metadatajson)
+	writeJSON(w, contentType, response)
 }
 
 // nameFilter sets all Elements in datasets to nil when string does not match
-func nameFilter(datasets []os.FileInfo, name string) error {
+func nameFilter(datasets []*Product, name string) error {
 	for index := range datasets {
-		match, err := regexp.MatchString(name, datasets[index].Name())
+		match, err := regexp.MatchString(name, datasets[index].Name)
 		if err != nil {
 			return err
 		}
@@ -158,8 +200,167 @@ func nameFilter(datasets []os.FileInfo, name string) error {
 	return nil
 }
 
-// metaDataFilter sets all Elements in datasets to nil when generationTime is not within bounds set by startDate and endDate or does not intersect bbox.
-func metaDataFilter(datasets []os.FileInfo, startDateRAW, endDateRAW string, bbox *geos.Geometry, filterDates, filterBox bool) error {
+// cloudFilter sets all Elements in datasets to nil when their cloud cover is unknown or not within minCloud and maxCloud percent
+func cloudFilter(datasets []*Product, minCloudRAW, maxCloudRAW string) error {
+	minCloud, maxCloud := 0.0, 100.0
+	var err error
+	if minCloudRAW != "" {
+		minCloud, err = strconv.ParseFloat(minCloudRAW, 64)
+		if err != nil {
+			return err
+		}
+	}
+	if maxCloudRAW != "" {
+		maxCloud, err = strconv.ParseFloat(maxCloudRAW, 64)
+		if err != nil {
+			return err
+		}
+	}
+	for index := range datasets {
+		if datasets[index] == nil {
+			continue
+		}
+		cloudCover := datasets[index].CloudCover
+		if cloudCover == nil || *cloudCover < minCloud || *cloudCover > maxCloud {
+			datasets[index] = nil
+		}
+	}
+	return nil
+}
+
+// propertiesFilter applies the cloud cover and property filters given in q
+func propertiesFilter(datasets []*Product, q url.Values) error {
+	if q.Get("mincloud") != "" || q.Get("maxcloud") != "" {
+		err := cloudFilter(datasets, q.Get("mincloud"), q.Get("maxcloud"))
+		if err != nil {
+			return errors.New("Unable to filter by cloud cover: " + err.Error())
+		}
+	}
+	for _, filter := range propertyFilters {
+		if q.Get(filter.param) == "" {
+			continue
+		}
+		err := propertyFilter(datasets, q.Get(filter.param), filter.normalize, filter.property)
+		if err != nil {
+			return errors.New("Unable to filter by " + filter.param + ": " + err.Error())
+		}
+	}
+	return nil
+}
+
+// propertyFilters lists the filters by product properties supported by /search
+var propertyFilters = []struct {
+	param     string
+	normalize func(string) (string, error)
+	property  func(*Product) string
+}{
+	{"tile", normalizeTile, func(p *Product) string { return p.TileID }},
+	{"orbit", normalizeOrbit, func(p *Product) string { return strconv.Itoa(p.RelativeOrbit) }},
+	{"platform", normalizePlatform, func(p *Product) string { return p.Platform }},
+	{"processingbaseline", normalizeBaseline, func(p *Product) string { return p.ProcessingBaseline }},
+	{"level", normalizeLevel, func(p *Product) string { return p.Level }},
+}
+
+// propertyFilter sets all Elements in datasets to nil when property is not one of the comma separated values
+func propertyFilter(datasets []*Product, values string, normalize func(string) (string, error), property func(*Product) string) error {
+	allowed := make(map[string]bool)
+	for _, value := range strings.Split(values, ",") {
+		normalized, err := normalize(value)
+		if err != nil {
+			return err
+		}
+		allowed[normalized] = true
+	}
+	for index := range datasets {
+		if datasets[index] != nil && !allowed[property(datasets[index])] {
+			datasets[index] = nil
+		}
+	}
+	return nil
+}
+
+// normalizeTile converts MGRS tiles like T32ULC or 32ulc to the 32ULC notation
+func normalizeTile(tile string) (string, error) {
+	tile = strings.TrimPrefix(strings.ToUpper(strings.TrimSpace(tile)), "T")
+	if len(tile) != 5 {
+		return "", errors.New("Invalid tile '" + tile + "'")
+	}
+	return tile, nil
+}
+
+// normalizeOrbit converts relative orbits like R031 or 31 to a number
+func normalizeOrbit(orbit string) (string, error) {
+	number, err := strconv.Atoi(strings.TrimPrefix(strings.ToUpper(strings.TrimSpace(orbit)), "R"))
+	if err != nil || number < 1 {
+		return "", errors.New("Invalid orbit '" + orbit + "'")
+	}
+	return strconv.Itoa(number), nil
+}
+
+// normalizeLevel converts processing levels like l2a or MSIL2A to L1C or L2A
+func normalizeLevel(level string) (string, error) {
+	level = strings.TrimPrefix(strings.ToUpper(strings.TrimSpace(level)), "MSI")
+	if level != "L1C" && level != "L2A" {
+		return "", errors.New("Invalid level '" + level + "', use L1C or L2A")
+	}
+	return level, nil
+}
+
+// sortProducts sorts datasets by date, cloud or name in ascending or descending order and returns the order used.
+// Dates are sorted descending by default, everything else ascending. Products with unknown cloud cover come last.
+func sortProducts(datasets []*Product, by, order string) (func(a, b *Product) bool, error) {
+	var less func(a, b *Product) bool
+	descending := false
+	switch by {
+	case "", "date":
+		descending = true
+		less = func(a, b *Product) bool {
+			if !a.SensingTime.Equal(b.SensingTime) {
+				return a.SensingTime.Before(b.SensingTime)
+			}
+			return a.Name < b.Name
+		}
+	case "cloud":
+		less = func(a, b *Product) bool {
+			if a.CloudCover == nil || b.CloudCover == nil || *a.CloudCover == *b.CloudCover {
+				// Keep unknown cloud cover last, less is called with swapped arguments when descending
+				if (a.CloudCover == nil) != (b.CloudCover == nil) {
+					return (a.CloudCover == nil) == descending
+				}
+				return a.Name < b.Name
+			}
+			return *a.CloudCover < *b.CloudCover
+		}
+	case "name":
+		less = func(a, b *Product) bool { return a.Name < b.Name }
+	default:
+		return nil, errors.New("Unknown sort field '" + by + "', use date, cloud or name")
+	}
+
+	switch order {
+	case "":
+	case "asc":
+		descending = false
+	case "desc":
+		descending = true
+	default:
+		return nil, errors.New("Unknown sort order '" + order + "', use asc or desc")
+	}
+
+	before := func(a, b *Product) bool {
+		if descending {
+			return less(b, a)
+		}
+		return less(a, b)
+	}
+	sort.SliceStable(datasets, func(i, j int) bool {
+		return before(datasets[i], datasets[j])
+	})
+	return before, nil
+}
+
+// metaDataFilter sets all Elements in datasets to nil when generationTime is not within bounds set by startDate and endDate or the footprint does not match spatial.
+func metaDataFilter(datasets []*Product, startDateRAW, endDateRAW string, spatial *spatialFilter, filterDates bool) error {
 	var startDate, endDate time.Time
 	if filterDates {
 		var err, err2 error
@@ -178,154 +379,212 @@ func metaDataFilter(datasets []os.FileInfo, startDateRAW, endDateRAW string, bbo
 			continue
 		}
 
-		var generationTimeRAW, footprintRAW string
-		var dataset *gdal.Dataset
-		var err error
-
-		// Try to get L1C Metadata
-		dataset, err = gdal.Open(DataSource+datasets[index].Name()+"/MTD_MSIL1C.xml", gdal.ReadOnly)
-		if err == nil {
-			generationTimeRAW, footprintRAW, err = getMetadataItems(dataset.Metadata(""))
-			if err != nil {
-				return err
-			}
-		} else {
-			// Else Try to get S2A Metadata
-			dataset, err = gdal.Open(DataSource+datasets[index].Name()+"/MTD_MSIL2A.xml", gdal.ReadOnly)
-			if err == nil {
-				generationTimeRAW, footprintRAW, err = getMetadataItems(dataset.Metadata(""))
-				if err != nil {
-					return err
-				}
-			} else {
-				return err
-			}
-		}
-
 		// Apply Date Filter if selected
 		if filterDates {
-			// Conversion to usable Time
-			generationTime, err := time.Parse(time.RFC3339, generationTimeRAW)
-			if err != nil {
-				return err
-			}
 			// Check if Dataset Generation Time is between specified Dates
+			generationTime := datasets[index].GenerationTime
 			if generationTime.Before(startDate) || generationTime.After(endDate) {
 				datasets[index] = nil
+				continue
 			}
-
 		}
 
-		if filterBox {
-			// Convert to usable Geometry
-			footprint, err := geos.FromWKT(footprintRAW)
-			if err != nil {
-				return err
-			}
-
-			// Check if Dataset overlaps with bbox
-			intersects, err := footprint.Intersects(bbox)
+		if spatial != nil {
+			// Check if Dataset footprint matches area
+			match, err := spatial.matches(datasets[index].Footprint)
 			if err != nil {
 				return err
 			}
-			// Check if Dataset Generation Time is between specified Dates
-			if !intersects {
+			if !match {
 				datasets[index] = nil
 			}
 		}
-		// Close Dataset
-		dataset.Close()
 	}
 	return nil
 }
 
-// Gets the metaData for 8 items starting with element page*8.
-func getMetaData(datasets []os.FileInfo, page int) (metadataL1C, metadataL2A []byte, totalcounter int, error error) {
-	// Start assembling Metadata
-	metadataL1C = []byte("{\"L1C\":[ ")
-	metadataL2A = []byte("\"L2A\":[ ")
-	// If L2A Dataset add additional Metadata
-	var L2A bool
-	// Total counts of elements found in datasets
-	totalcounter = 0
-	// Number of Elements pushed into channel
-	metadatacounter := 0
-	// Number of Elements per Page
-	pagesize := 8
+// parseSpatialFilter creates a spatial filter from the bbox or intersects, predicate and minoverlap parameters in q.
+// It returns nil if no area is given.
+func parseSpatialFilter(q url.Values) (*spatialFilter, error) {
+	var area *geos.Geometry
+	var err error
+	if q.Get("bbox") != "" && q.Get("intersects") != "" {
+		return nil, errors.New("Only one of bbox and intersects can be used")
+	}
+	if q.Get("bbox") != "" {
+		var bbox [4]float64
+		bbox, err = parseBbox(q.Get("bbox"))
+		if err == nil {
+			area, err = bboxGeometry(bbox)
+		}
+	}
+	if q.Get("intersects") != "" {
+		area, err = parseGeometry(q.Get("intersects"))
+	}
+	if err != nil || area == nil {
+		return nil, err
+	}
+	if Verbose {
+		fmt.Println("Parsed area from request as: " + area.String())
+	}
+	return newSpatialFilter(area, q.Get("predicate"), q.Get("minoverlap"))
+}
+
+// spatialFilter selects products by the relation of their footprint to an area
+type spatialFilter struct {
+	area       *geos.Geometry
+	predicate  string
+	minOverlap float64
+	areaSize   float64
+}
+
+// newSpatialFilter creates a filter for footprints that intersect, contain or are within area
+// and cover at least minOverlap percent of it
+func newSpatialFilter(area *geos.Geometry, predicate, minOverlapRAW string) (*spatialFilter, error) {
+	f := &spatialFilter{area: area, predicate: predicate}
+	switch predicate {
+	case "":
+		f.predicate = "intersects"
+	case "intersects", "contains", "within":
+	default:
+		return nil, errors.New("Unknown predicate '" + predicate + "', use intersects, contains or within")
+	}
+
+	if minOverlapRAW != "" {
+		var err error
+		f.minOverlap, err = strconv.ParseFloat(minOverlapRAW, 64)
+		if err != nil || f.minOverlap < 0 || f.minOverlap > 100 {
+			return nil, errors.New("minoverlap must be a percentage between 0 and 100")
+		}
+		f.areaSize, err = area.Area()
+		if err != nil {
+			return nil, err
+		}
+		if f.areaSize == 0 {
+			return nil, errors.New("minoverlap can only be used with polygonal areas")
+		}
+	}
+	return f, nil
+}
+
+// matches reports whether the footprint given as WKT matches the filter
+func (f *spatialFilter) matches(footprintWKT string) (bool, error) {
+	footprint, err := geos.FromWKT(footprintWKT)
+	if err != nil {
+		return false, err
+	}
+
+	var match bool
+	switch f.predicate {
+	case "contains":
+		match, err = footprint.Contains(f.area)
+	case "within":
+		match, err = footprint.Within(f.area)
+	default:
+		match, err = footprint.Intersects(f.area)
+	}
+	if err != nil || !match || f.minOverlap == 0 {
+		return match, err
+	}
+
+	// Compute share of area covered by footprint
+	intersection, err := footprint.Intersection(f.area)
+	if err != nil {
+		return false, err
+	}
+	overlap, err := intersection.Area()
+	if err != nil {
+		return false, err
+	}
+	return overlap/f.areaSize*100 >= f.minOverlap, nil
+}
 
+// searchPage returns up to limit remaining datasets on page or next to cursor,
+// the cursors of the previous and next page and the number of all remaining datasets.
+// Datasets must be sorted by before.
+func searchPage(datasets []*Product, page, limit int, cursor *searchCursor, before func(a, b *Product) bool) (results []*Product, prev, next string, totalcounter int) {
+	// Skip Datasets sorted out by filters
+	var remaining []*Product
 	for index := range datasets {
-		// Fetch Metadata if Dataset is not sorted out + is on correct page
 		if datasets[index] != nil {
-			totalcounter++
-			// Push to metadata if on correct page and not already full
-			if totalcounter > page*pagesize && metadatacounter < pagesize {
-				metadatacounter++
-
-				// Try to open and read Metadata of L1C Dataset
-				dataset, err := gdal.Open(
-					DataSource+datasets[index].Name()+"/MTD_MSIL1C.xml",
-					gdal.ReadOnly)
-				if err == nil {
-					L2A = false
-					createJSON(append(dataset.Metadata(""), dataset.Metadata("Subdatasets")...), &metadataL1C)
-					dataset.Close()
-				} else {
-					// Try to open and read Metadata of L2A Dataset
-					dataset, err := gdal.Open(
-						DataSource+datasets[index].Name()+"/MTD_MSIL2A.xml",
-						gdal.ReadOnly)
-					if err == nil {
-						L2A = true
-						createJSON(dataset.Metadata(""), &metadataL2A)
-						dataset.Close()
-					} else {
-						return nil, nil, 0, err
-					}
-				}
-				if L2A {
-					// Get datast location (with dynamic folder name)
-					datalocation := DataSource + datasets[index].Name() + "/GRANULE/"
-					datasetname, err := ioutil.ReadDir(datalocation)
-					if err != nil {
-						return nil, nil, 0, err
-					}
-					//
-					location := datalocation + datasetname[0].Name()
-					datasetsR10M, err := ioutil.ReadDir(location + "/IMG_DATA/R10m/")
-					if err != nil {
-						return nil, nil, 0, err
-					}
-					datasetsR20M, err := ioutil.ReadDir(location + "/IMG_DATA/R20m/")
-					if err != nil {
-						return nil, nil, 0, err
-					}
-					datasetsR60M, err := ioutil.ReadDir(location + "/IMG_DATA/R60m/")
-					if err != nil {
-						return nil, nil, 0, err
-					}
-
-					var datasetsR10Mstring string
-					for i := range datasetsR10M {
-						datasetsR10Mstring += datasetsR10M[i].Name() + "\",\""
-					}
-					var datasetsR20Mstring string
-					for i := range datasetsR20M {
-						datasetsR20Mstring += datasetsR20M[i].Name() + "\",\""
-					}
-					var datasetsR60Mstring string
-					for i := range datasetsR60M {
-						datasetsR60Mstring += datasetsR60M[i].Name() + "\",\""
-					}
-
-					metadataL2A = metadataL2A[:len(metadataL2A)-2]
-					metadataL2A = append(metadataL2A, []byte(",\"R10M\":[\""+datasetsR10Mstring[:len(datasetsR10Mstring)-2]+"]")...)
-					metadataL2A = append(metadataL2A, []byte(",\"R20M\":[\""+datasetsR20Mstring[:len(datasetsR20Mstring)-2]+"]")...)
-					metadataL2A = append(metadataL2A, []byte(",\"R60M\":[\""+datasetsR60Mstring[:len(datasetsR60Mstring)-2]+"]},")...)
-				}
-			}
+			remaining = append(remaining, datasets[index])
+		}
+	}
+	totalcounter = len(remaining)
+
+	// Get range of page. Cursors reference products by their sort keys, so pages are stable when products are added or removed
+	var start, end int
+	switch {
+	case cursor == nil:
+		start = page * limit
+		if start > totalcounter {
+			start = totalcounter
+		}
+		end = start + limit
+	case cursor.Before:
+		end = sort.Search(totalcounter, func(i int) bool { return !before(remaining[i], cursor.product()) })
+		start = end - limit
+		if start < 0 {
+			start = 0
 		}
+	default:
+		start = sort.Search(totalcounter, func(i int) bool { return before(cursor.product(), remaining[i]) })
+		end = start + limit
 	}
-	return metadataL1C, metadataL2A, totalcounter, nil
+	if end > totalcounter {
+		end = totalcounter
+	}
+	results = remaining[start:end]
+
+	if start > 0 && len(results) > 0 {
+		prev = newSearchCursor(results[0], true)
+	}
+	if end < totalcounter && len(results) > 0 {
+		next = newSearchCursor(results[len(results)-1], false)
+	}
+	return results, prev, next, totalcounter
+}
+
+// searchCursor holds the sort keys of the first or last product of a page
+type searchCursor struct {
+	Name       string    `json:"n"`
+	Date       time.Time `json:"d"`
+	CloudCover *float64  `json:"c,omitempty"`
+	// Before is set for cursors to the previous page
+	Before bool `json:"b,omitempty"`
+}
+
+// newSearchCursor returns the encoded cursor to the products after or before p
+func newSearchCursor(p *Product, before bool) string {
+	cursor, _ := json.Marshal(searchCursor{Name: p.Name, Date: p.SensingTime, CloudCover: p.CloudCover, Before: before})
+	return base64.RawURLEncoding.EncodeToString(cursor)
+}
+
+// parseSearchCursor decodes a cursor created by newSearchCursor
+func parseSearchCursor(s string) (*searchCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, errors.New("Invalid cursor")
+	}
+	cursor := &searchCursor{}
+	err = json.Unmarshal(raw, cursor)
+	if err != nil || cursor.Name == "" {
+		return nil, errors.New("Invalid cursor")
+	}
+	return cursor, nil
+}
+
+// product returns a product with the sort keys of the cursor
+func (c *searchCursor) product() *Product {
+	return &Product{Name: c.Name, SensingTime: c.Date, CloudCover: c.CloudCover}
+}
+
+// searchLink returns the URL of the request with page replaced by cursor
+func searchLink(r *http.Request, cursor string) string {
+	q := r.URL.Query()
+	q.Del("page")
+	q.Set("cursor", cursor)
+	return baseURL(r) + r.URL.Path + "?" + q.Encode()
 }
 
 // getMetadataItems gets the Metadataitems 'GENERATION_TIME' and 'FOOTPRINT' from Dataset Metadata
@@ -349,26 +608,9 @@ func getMetadataItems(metadata []string) (generationTimeRAW, footprintRAW string
 // extractMetadata gets the string containing keyword from the slice
 func extractMetadata(metadata []string, keyword string) string {
 	for index := range metadata {
-		if metadata[index][:len(keyword)] == keyword {
+		if strings.HasPrefix(metadata[index], keyword) {
 			return metadata[index]
 		}
 	}
 	return ""
 }
-
-// createJson creates a JSON Object as byte slice from gdalinfo output
-func createJSON(input []string, output *[]byte) error {
-	// Convert into JSON
-	fields := make(map[string]string)
-	for index := range input {
-		keyValuePair := strings.Split(input[index], "=")
-		fields[keyValuePair[0]] = keyValuePair[1]
-	}
-	jsonstring, err := json.Marshal(fields)
-	if err != nil {
-		return err
-	}
-	jsonstring = append(jsonstring, []byte(",")...)
-	*output = append(*output, jsonstring...)
-	return nil
-}
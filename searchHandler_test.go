@@ -0,0 +1,369 @@
+package main
+
+import (
+	"github.com/paulsmith/gogeos/geos"
+	"net/url"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestSearchCursor(t *testing.T) {
+	cloud := 12.5
+	p := &Product{Name: "S2A_MSIL2A_20180505T103021.SAFE", SensingTime: time.Date(2018, 5, 5, 10, 30, 21, 0, time.UTC), CloudCover: &cloud}
+	for _, before := range []bool{false, true} {
+		cursor, err := parseSearchCursor(newSearchCursor(p, before))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cursor.Before != before || !reflect.DeepEqual(cursor.product(), p) {
+			t.Errorf("cursor = %+v, want sort keys of %+v and before %v", cursor, p, before)
+		}
+	}
+
+	for _, s := range []string{"", "not base64!", "bnVsbA", "e30"} {
+		if _, err := parseSearchCursor(s); err == nil {
+			t.Errorf("parseSearchCursor(%q) succeeded, want error", s)
+		}
+	}
+}
+
+// searchTestProducts returns n products sorted by date descending together with the order
+func searchTestProducts(n int) ([]*Product, func(a, b *Product) bool) {
+	var products []*Product
+	for i := 0; i < n; i++ {
+		products = append(products, &Product{
+			Name:        "P" + strconv.Itoa(i),
+			SensingTime: time.Date(2018, 1, 1+i, 0, 0, 0, 0, time.UTC),
+		})
+	}
+	before, _ := sortProducts(products, "date", "")
+	return products, before
+}
+
+func names(products []*Product) []string {
+	var result []string
+	for _, p := range products {
+		result = append(result, p.Name)
+	}
+	return result
+}
+
+func TestSearchPage(t *testing.T) {
+	products, before := searchTestProducts(5)
+
+	// First page
+	results, prev, next, total := searchPage(products, 0, 2, nil, before)
+	if got := names(results); !reflect.DeepEqual(got, []string{"P4", "P3"}) || prev != "" || next == "" || total != 5 {
+		t.Fatalf("first page = %v, prev %q, next %q, total %d", got, prev, next, total)
+	}
+
+	// Follow next cursor
+	cursor, err := parseSearchCursor(next)
+	if err != nil {
+		t.Fatal(err)
+	}
+	results, prev, next, _ = searchPage(products, 0, 2, cursor, before)
+	if got := names(results); !reflect.DeepEqual(got, []string{"P2", "P1"}) || prev == "" || next == "" {
+		t.Fatalf("second page = %v, prev %q, next %q", got, prev, next)
+	}
+
+	// Pages stay stable when a newer product is ingested
+	products, before = searchTestProducts(6)
+	cursor, err = parseSearchCursor(next)
+	if err != nil {
+		t.Fatal(err)
+	}
+	results, _, next, _ = searchPage(products, 0, 2, cursor, before)
+	if got := names(results); !reflect.DeepEqual(got, []string{"P0"}) || next != "" {
+		t.Fatalf("last page = %v, next %q", got, next)
+	}
+
+	// Follow prev cursor back
+	cursor, err = parseSearchCursor(prev)
+	if err != nil {
+		t.Fatal(err)
+	}
+	results, _, _, _ = searchPage(products, 0, 2, cursor, before)
+	if got := names(results); !reflect.DeepEqual(got, []string{"P4", "P3"}) {
+		t.Fatalf("previous page = %v", got)
+	}
+
+	// Filtered products are skipped and pages beyond the end are empty
+	products[0] = nil
+	results, _, _, total = searchPage(products, 3, 2, nil, before)
+	if len(results) != 0 || total != 5 {
+		t.Errorf("page beyond end = %v, total %d", names(results), total)
+	}
+}
+
+// sortTestProducts returns products with distinct sensing times, one with unknown cloud cover
+func sortTestProducts() []*Product {
+	cloud := func(v float64) *float64 { return &v }
+	day := func(d int) time.Time { return time.Date(2018, 5, d, 10, 30, 0, 0, time.UTC) }
+	return []*Product{
+		{Name: "A", SensingTime: day(3), CloudCover: cloud(10)},
+		{Name: "B", SensingTime: day(1)},
+		{Name: "C", SensingTime: day(2), CloudCover: cloud(5)},
+		{Name: "D", SensingTime: day(4), CloudCover: cloud(10)},
+	}
+}
+
+func TestSortProducts(t *testing.T) {
+	tests := []struct {
+		by, order string
+		want      []string
+	}{
+		{"", "", []string{"D", "A", "C", "B"}},
+		{"date", "desc", []string{"D", "A", "C", "B"}},
+		{"date", "asc", []string{"B", "C", "A", "D"}},
+		{"", "asc", []string{"B", "C", "A", "D"}},
+		{"cloud", "", []string{"C", "A", "D", "B"}},
+		{"cloud", "asc", []string{"C", "A", "D", "B"}},
+		{"cloud", "desc", []string{"D", "A", "C", "B"}},
+		{"name", "", []string{"A", "B", "C", "D"}},
+		{"name", "desc", []string{"D", "C", "B", "A"}},
+	}
+	for _, test := range tests {
+		products := sortTestProducts()
+		before, err := sortProducts(products, test.by, test.order)
+		if err != nil {
+			t.Errorf("sortProducts(%q, %q) failed: %v", test.by, test.order, err)
+			continue
+		}
+		if got := names(products); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("sortProducts(%q, %q) = %v, want %v", test.by, test.order, got, test.want)
+		}
+		for i := 1; i < len(products); i++ {
+			if before(products[i], products[i-1]) {
+				t.Errorf("sortProducts(%q, %q) returned order placing %s before %s", test.by, test.order, products[i].Name, products[i-1].Name)
+			}
+		}
+	}
+
+	for _, test := range [][2]string{{"size", ""}, {"date", "up"}, {"Cloud", "asc"}} {
+		if _, err := sortProducts(sortTestProducts(), test[0], test[1]); err == nil {
+			t.Errorf("sortProducts(%q, %q) succeeded, want error", test[0], test[1])
+		}
+	}
+}
+
+func TestCloudFilter(t *testing.T) {
+	tests := []struct {
+		min, max string
+		want     []string
+	}{
+		{"", "", []string{"A", "C", "D"}},
+		{"", "5", []string{"C"}},
+		{"6", "", []string{"A", "D"}},
+		{"5", "10", []string{"A", "C", "D"}},
+		{"11", "", nil},
+	}
+	for _, test := range tests {
+		products := sortTestProducts()
+		err := cloudFilter(products, test.min, test.max)
+		if err != nil {
+			t.Errorf("cloudFilter(%q, %q) failed: %v", test.min, test.max, err)
+			continue
+		}
+		var got []string
+		for _, p := range products {
+			if p != nil {
+				got = append(got, p.Name)
+			}
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("cloudFilter(%q, %q) kept %v, want %v", test.min, test.max, got, test.want)
+		}
+	}
+	for _, test := range [][2]string{{"low", ""}, {"", "50%"}} {
+		if err := cloudFilter(sortTestProducts(), test[0], test[1]); err == nil {
+			t.Errorf("cloudFilter(%q, %q) succeeded, want error", test[0], test[1])
+		}
+	}
+}
+
+// boxWKT returns an axis-aligned rectangle as WKT polygon
+func boxWKT(minx, miny, maxx, maxy float64) string {
+	f := func(v float64) string { return strconv.FormatFloat(v, 'f', -1, 64) }
+	return "POLYGON((" + f(minx) + " " + f(miny) + ", " + f(maxx) + " " + f(miny) + ", " + f(maxx) + " " + f(maxy) + ", " +
+		f(minx) + " " + f(maxy) + ", " + f(minx) + " " + f(miny) + "))"
+}
+
+func TestNewSpatialFilter(t *testing.T) {
+	area, err := bboxGeometry([4]float64{0, 0, 10, 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct{ predicate, minOverlap string }{
+		{"", ""},
+		{"intersects", "0"},
+		{"contains", "100"},
+		{"within", "50.5"},
+	} {
+		if _, err := newSpatialFilter(area, test.predicate, test.minOverlap); err != nil {
+			t.Errorf("newSpatialFilter(%q, %q) failed: %v", test.predicate, test.minOverlap, err)
+		}
+	}
+	for _, test := range []struct{ predicate, minOverlap string }{
+		{"overlaps", ""},
+		{"Intersects", ""},
+		{"", "-1"},
+		{"", "100.1"},
+		{"", "half"},
+	} {
+		if _, err := newSpatialFilter(area, test.predicate, test.minOverlap); err == nil {
+			t.Errorf("newSpatialFilter(%q, %q) succeeded, want error", test.predicate, test.minOverlap)
+		}
+	}
+
+	// Points have no area to overlap
+	point, err := geos.NewPoint(geos.Coord{X: 5, Y: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := newSpatialFilter(point, "", "10"); err == nil {
+		t.Error("newSpatialFilter of a point with minoverlap succeeded, want error")
+	}
+}
+
+func TestSpatialFilterMatches(t *testing.T) {
+	area, err := bboxGeometry([4]float64{0, 0, 10, 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	footprints := []struct {
+		name string
+		wkt  string
+	}{
+		{"same", boxWKT(0, 0, 10, 10)},
+		{"larger", boxWKT(-5, -5, 15, 15)},
+		{"inside", boxWKT(2, 2, 4, 4)},
+		{"half", boxWKT(5, 0, 15, 10)},
+		{"corner", boxWKT(9, 9, 20, 20)},
+		{"disjoint", boxWKT(20, 20, 30, 30)},
+	}
+	tests := []struct {
+		predicate, minOverlap string
+		want                  []string
+	}{
+		{"", "", []string{"same", "larger", "inside", "half", "corner"}},
+		{"contains", "", []string{"same", "larger"}},
+		{"within", "", []string{"same", "inside"}},
+		{"intersects", "0", []string{"same", "larger", "inside", "half", "corner"}},
+		{"intersects", "1", []string{"same", "larger", "inside", "half", "corner"}},
+		{"intersects", "1.01", []string{"same", "larger", "inside", "half"}},
+		{"intersects", "49.99", []string{"same", "larger", "half"}},
+		{"intersects", "50", []string{"same", "larger", "half"}},
+		{"intersects", "50.01", []string{"same", "larger"}},
+		{"intersects", "100", []string{"same", "larger"}},
+		{"within", "100", []string{"same"}},
+	}
+	for _, test := range tests {
+		filter, err := newSpatialFilter(area, test.predicate, test.minOverlap)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, footprint := range footprints {
+			match, err := filter.matches(footprint.wkt)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if match {
+				got = append(got, footprint.name)
+			}
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("predicate %q with minoverlap %q matches %v, want %v", test.predicate, test.minOverlap, got, test.want)
+		}
+	}
+}
+
+func TestNormalizeProperties(t *testing.T) {
+	tests := []struct {
+		name      string
+		normalize func(string) (string, error)
+		valid     map[string]string
+		invalid   []string
+	}{
+		{"tile", normalizeTile,
+			map[string]string{"32ULC": "32ULC", "T32ULC": "32ULC", " t32ulc ": "32ULC"},
+			[]string{"", "T32UL", "32ULCC", "TT32ULC"}},
+		{"orbit", normalizeOrbit,
+			map[string]string{"R031": "31", "r31": "31", "31": "31", " 8 ": "8"},
+			[]string{"", "R", "0", "-3", "R03a", "31.5"}},
+		{"platform", normalizePlatform,
+			map[string]string{"S2A": "S2A", "s2b": "S2B", "2a": "S2A", "Sentinel-2B": "S2B"},
+			[]string{"", "S2", "S1A", "S2AB", "Sentinel-1A", "2_"}},
+		{"processingbaseline", normalizeBaseline,
+			map[string]string{"02.06": "02.06", "2.6": "02.06", "N0206": "02.06", "n0400": "04.00", "0207": "02.07"},
+			[]string{"", "02.", ".06", "020", "N02060", "ab.cd"}},
+		{"level", normalizeLevel,
+			map[string]string{"L1C": "L1C", "l2a": "L2A", "MSIL2A": "L2A", " msil1c ": "L1C"},
+			[]string{"", "L1", "L2B", "MSI"}},
+	}
+	for _, test := range tests {
+		for value, want := range test.valid {
+			got, err := test.normalize(value)
+			if err != nil || got != want {
+				t.Errorf("%s: normalize(%q) = %q, %v, want %q", test.name, value, got, err, want)
+			}
+		}
+		for _, value := range test.invalid {
+			if got, err := test.normalize(value); err == nil {
+				t.Errorf("%s: normalize(%q) = %q, want error", test.name, value, got)
+			}
+		}
+	}
+}
+
+func TestPropertiesFilter(t *testing.T) {
+	products := func() []*Product {
+		return []*Product{
+			{Name: "A", TileID: "32ULC", RelativeOrbit: 8, Platform: "S2A", ProcessingBaseline: "02.06", Level: "L1C"},
+			{Name: "B", TileID: "32ULC", RelativeOrbit: 108, Platform: "S2B", ProcessingBaseline: "02.07", Level: "L2A"},
+			{Name: "C", TileID: "32UMC", RelativeOrbit: 8, Platform: "S2B", ProcessingBaseline: "02.07", Level: "L2A"},
+		}
+	}
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"", []string{"A", "B", "C"}},
+		{"tile=T32ULC", []string{"A", "B"}},
+		{"tile=32ulc,32umc", []string{"A", "B", "C"}},
+		{"orbit=R008", []string{"A", "C"}},
+		{"platform=Sentinel-2B&level=l2a", []string{"B", "C"}},
+		{"processingbaseline=N0206", []string{"A"}},
+		{"tile=32UMC&orbit=108", nil},
+	}
+	for _, test := range tests {
+		q, err := url.ParseQuery(test.query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		datasets := products()
+		err = propertiesFilter(datasets, q)
+		if err != nil {
+			t.Errorf("propertiesFilter(%q) failed: %v", test.query, err)
+			continue
+		}
+		var got []string
+		for _, p := range datasets {
+			if p != nil {
+				got = append(got, p.Name)
+			}
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("propertiesFilter(%q) kept %v, want %v", test.query, got, test.want)
+		}
+	}
+	for _, query := range []string{"tile=32UL", "orbit=0", "platform=S3A", "processingbaseline=x", "level=L3"} {
+		q, _ := url.ParseQuery(query)
+		if err := propertiesFilter(products(), q); err == nil {
+			t.Errorf("propertiesFilter(%q) succeeded, want error", query)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"github.com/paulsmith/gogeos/geos"
+	"time"
+)
+
+// Response Schema of /search
+type searchResponse struct {
+	L1C []searchResult `json:"L1C"`
+	L2A []searchResult `json:"L2A"`
+	// Cursors to the previous and next page
+	Prev string `json:"prev,omitempty"`
+	Next string `json:"next,omitempty"`
+}
+
+type searchResult struct {
+	Name               string            `json:"name"`
+	Level              string            `json:"level"`
+	Date               time.Time         `json:"date"`
+	GenerationTime     time.Time         `json:"generationTime"`
+	CloudCover         *float64          `json:"cloudCover"`
+	Tile               string            `json:"tile"`
+	Orbit              int               `json:"orbit"`
+	Platform           string            `json:"platform"`
+	ProcessingBaseline string            `json:"processingBaseline"`
+	Footprint          *geoJSONGeometry  `json:"footprint,omitempty"`
+	Bands              []string          `json:"bands"`
+	R10M               []string          `json:"R10M,omitempty"`
+	R20M               []string          `json:"R20M,omitempty"`
+	R60M               []string          `json:"R60M,omitempty"`
+	Metadata           map[string]string `json:"metadata"`
+}
+
+// Response Schema of /search?format=geojson
+type searchFeatureCollection struct {
+	Type     string          `json:"type"`
+	Features []searchFeature `json:"features"`
+	Prev     string          `json:"prev,omitempty"`
+	Next     string          `json:"next,omitempty"`
+}
+
+type searchFeature struct {
+	Type       string          `json:"type"`
+	ID         string          `json:"id"`
+	Geometry   geoJSONGeometry `json:"geometry"`
+	Properties searchResult    `json:"properties"`
+}
+
+// newSearchResponse groups products by processing level
+func newSearchResponse(products []*Product, prev, next string) (*searchResponse, error) {
+	response := &searchResponse{L1C: []searchResult{}, L2A: []searchResult{}, Prev: prev, Next: next}
+	for _, p := range products {
+		result, footprint, err := newSearchResult(p)
+		if err != nil {
+			return nil, err
+		}
+		result.Footprint = &footprint
+		if p.Level == "L1C" {
+			response.L1C = append(response.L1C, result)
+		} else {
+			response.L2A = append(response.L2A, result)
+		}
+	}
+	return response, nil
+}
+
+// newSearchFeatureCollection returns products as GeoJSON Features with their footprint as geometry
+func newSearchFeatureCollection(products []*Product, prev, next string) (*searchFeatureCollection, error) {
+	collection := &searchFeatureCollection{Type: "FeatureCollection", Features: []searchFeature{}, Prev: prev, Next: next}
+	for _, p := range products {
+		result, footprint, err := newSearchResult(p)
+		if err != nil {
+			return nil, err
+		}
+		collection.Features = append(collection.Features, searchFeature{
+			Type:       "Feature",
+			ID:         p.Name,
+			Geometry:   footprint,
+			Properties: result,
+		})
+	}
+	return collection, nil
+}
+
+// newSearchResult returns the typed search result and the parsed footprint of a product
+func newSearchResult(p *Product) (searchResult, geoJSONGeometry, error) {
+	result := searchResult{
+		Name:               p.Name,
+		Level:              p.Level,
+		Date:               p.SensingTime,
+		GenerationTime:     p.GenerationTime,
+		CloudCover:         p.CloudCover,
+		Tile:               p.TileID,
+		Orbit:              p.RelativeOrbit,
+		Platform:           p.Platform,
+		ProcessingBaseline: p.ProcessingBaseline,
+		Bands:              p.bands(),
+		Metadata:           p.Metadata,
+	}
+
+	// Add image files of L2A Datasets by resolution
+	if p.Level == "L2A" {
+		result.R10M = p.imagesIn("R10m/")
+		result.R20M = p.imagesIn("R20m/")
+		result.R60M = p.imagesIn("R60m/")
+	}
+
+	footprint, err := geos.FromWKT(p.Footprint)
+	if err != nil {
+		return result, geoJSONGeometry{}, err
+	}
+	geometry, err := geoJSONFromGeometry(footprint)
+	if err != nil {
+		return result, geoJSONGeometry{}, err
+	}
+	return result, geometry, nil
+}
@@ -21,6 +21,16 @@ func main() {
 	// Get command-line flags
 	filelocation := flag.String("src", "/opt/sentinel2/", "set source directory for datasets")
 	verbose := flag.Bool("v", false, "toggle verbose output")
+	workers := flag.Int("workers", 2, "set number of concurrent generation jobs")
+	queuelength := flag.Int("queue", 32, "set maximum number of queued generation jobs")
+	jobttl := flag.Duration("jobttl", time.Hour, "set how long the status and result of finished jobs are kept")
+	minzoom := flag.Int("minzoom", 4, "set lowest zoom level of generated tiles")
+	maxzoom := flag.Int("maxzoom", 12, "set highest zoom level of generated tiles")
+	tilecache := flag.Int("tilecache", 1024, "set number of dynamically rendered tiles kept in memory")
+	statscache := flag.Int("statscache", 64, "set number of band statistics kept in memory")
+	indexlocation := flag.String("index", "skylax.db", "set location of the metadata index")
+	watch := flag.Bool("watch", true, "toggle watching the source directory for new, changed and removed datasets")
+	rescan := flag.Duration("rescan", 10*time.Minute, "set interval for rescanning the source directory, 0 to disable")
 	flag.Parse()
 	if *verbose {
 		Verbose = true
@@ -28,12 +38,64 @@ func main() {
 	if *filelocation != "" {
 		DataSource = *filelocation
 	}
+	MinZoom = *minzoom
+	MaxZoom = *maxzoom
+
+	// Open metadata index and bring it up to date
+	var err error
+	Index, err = OpenIndex(*indexlocation)
+	if err != nil {
+		log.Fatal("Unable to open index: " + err.Error())
+	}
+	err = Index.Sync()
+	if err != nil {
+		fmt.Println("Unable to sync index: " + err.Error())
+	}
+	if *watch {
+		err = WatchDataSource(5 * time.Second)
+		if err != nil {
+			fmt.Println("Unable to watch source directory: " + err.Error())
+		}
+	}
+	if *rescan > 0 {
+		go Index.SyncPeriodically(*rescan)
+	}
+
+	// Start Workers for generation jobs
+	Jobs = NewJobQueue(*workers, *queuelength, *jobttl)
+
+	// Setup cache for dynamically rendered tiles
+	Tiles = newLRUCache(*tilecache)
+
+	// Setup cache for band statistics
+	Statistics = newLRUCache(*statscache)
 
 	// Create Routes
 	router := httprouter.New()
 	router.HandlerFunc("GET", "/search", SearchHandler)
+	router.HandlerFunc("GET", "/availability", AvailabilityHandler)
 	router.HandlerFunc("POST", "/generate", GenerateHandler)
+	router.HandlerFunc("POST", "/composite", CompositeHandler)
 	router.HandlerFunc("GET", "/value", LookupHandler)
+	router.HandlerFunc("GET", "/spectrum", SpectrumHandler)
+	router.HandlerFunc("GET", "/timeseries", TimeseriesHandler)
+	router.HandlerFunc("POST", "/zonalstats", ZonalStatsHandler)
+	router.HandlerFunc("POST", "/zonalstats/timeseries", ZonalTimeseriesHandler)
+	router.HandlerFunc("POST", "/profile", ProfileHandler)
+	router.HandlerFunc("GET", "/stats", StatsHandler)
+	router.GET("/jobs/:id", JobHandler)
+	router.GET("/tiles/:dataset/:z/:x/:y", TileHandler)
+	router.HandlerFunc("GET", "/colormaps", ColormapsHandler)
+	router.GET("/colormaps/:name", ColormapPreviewHandler)
+	router.HandlerFunc("GET", "/stac", StacLandingHandler)
+	router.HandlerFunc("GET", "/stac/conformance", StacConformanceHandler)
+	router.HandlerFunc("GET", "/stac/collections", StacCollectionsHandler)
+	router.GET("/stac/collections/:collection", StacCollectionHandler)
+	router.GET("/stac/collections/:collection/items", StacItemsHandler)
+	router.GET("/stac/collections/:collection/items/:item", StacItemHandler)
+	router.HandlerFunc("GET", "/stac/search", StacSearchHandler)
+	router.HandlerFunc("POST", "/stac/search", StacSearchHandler)
+	router.GET("/products/:product/:asset", StacAssetHandler)
 
 	// Set CORS Headers
 	handler := cors.Default().Handler(router)
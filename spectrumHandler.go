@@ -0,0 +1,139 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"github.com/ling-js/go-gdal"
+	"math"
+	"net/http"
+	"time"
+)
+
+// SpectralBands lists all Sentinel-2 MSI bands with central wavelength in nm (Sentinel-2A) and native resolution in m
+var SpectralBands = []struct {
+	Name       string
+	Wavelength float64
+	Resolution int
+}{
+	{"B01", 442.7, 60},
+	{"B02", 492.4, 10},
+	{"B03", 559.8, 10},
+	{"B04", 664.6, 10},
+	{"B05", 704.1, 20},
+	{"B06", 740.5, 20},
+	{"B07", 782.8, 20},
+	{"B08", 832.8, 10},
+	{"B8A", 864.7, 20},
+	{"B09", 945.1, 60},
+	{"B10", 1373.5, 60},
+	{"B11", 1613.7, 20},
+	{"B12", 2202.4, 20},
+}
+
+// Response Schema of /spectrum
+type spectrumResponse struct {
+	Dataset string           `json:"dataset"`
+	Lon     float64          `json:"lon"`
+	Lat     float64          `json:"lat"`
+	Bands   []spectrumResult `json:"bands"`
+}
+
+type spectrumResult struct {
+	Band        string   `json:"band"`
+	Wavelength  float64  `json:"wavelength"`
+	Resolution  int      `json:"resolution"`
+	DN          uint16   `json:"dn"`
+	Reflectance *float64 `json:"reflectance"`
+}
+
+// SpectrumHandler returns the reflectance of all spectral bands of a Dataset at a point
+func SpectrumHandler(w http.ResponseWriter, r *http.Request) {
+	defer Timetrack(time.Now(), "Spectrum ")
+	q := r.URL.Query()
+	datasetname := q.Get("d")
+
+	// Log request if verbose is set
+	if Verbose {
+		fmt.Print("Request to /spectrum with parameters: ")
+		fmt.Println(q)
+	}
+
+	if datasetname == "" {
+		w.WriteHeader(400)
+		w.Write([]byte("Parameter 'd' is required"))
+		return
+	}
+	lon, lat, err := parseLonLat(q.Get("x"), q.Get("y"))
+	if err != nil {
+		w.WriteHeader(400)
+		w.Write([]byte(err.Error()))
+		return
+	}
+	_, err = granuleLocation(productName(datasetname))
+	if err != nil {
+		w.WriteHeader(404)
+		w.Write([]byte("Cannot find Dataset: " + err.Error()))
+		return
+	}
+
+	response := spectrumResponse{
+		Dataset: productName(datasetname),
+		Lon:     lon,
+		Lat:     lat,
+		Bands:   []spectrumResult{},
+	}
+	for _, band := range SpectralBands {
+		// Use best available resolution, L2A products do not contain all bands
+		location, err := bandLocation(datasetname, band.Name)
+		if err == ErrBandNotFound {
+			continue
+		}
+		if err != nil {
+			w.WriteHeader(500)
+			w.Write([]byte("Unable to find Band " + band.Name + ": " + err.Error()))
+			return
+		}
+
+		values, _, _, err := readPixel(location, lon, lat)
+		if err == ErrOutsideDataset {
+			w.WriteHeader(400)
+			w.Write([]byte(err.Error()))
+			return
+		}
+		if err != nil {
+			w.WriteHeader(500)
+			w.Write([]byte("Error reading value of Band " + band.Name + ": " + err.Error()))
+			return
+		}
+		// Report the resolution of the file read, which is coarser than the native one for some L2A bands
+		resolution, err := rasterResolution(location)
+		if err != nil {
+			w.WriteHeader(500)
+			w.Write([]byte("Error reading resolution of Band " + band.Name + ": " + err.Error()))
+			return
+		}
+
+		result := spectrumResult{
+			Band:       band.Name,
+			Wavelength: band.Wavelength,
+			Resolution: resolution,
+			DN:         values[0],
+		}
+		if values[0] != 0 {
+			reflectance := float64(values[0]) / quantificationValue
+			result.Reflectance = &reflectance
+		}
+		response.Bands = append(response.Bands, result)
+	}
+	writeJSON(w, "application/json", response)
+}
+
+// rasterResolution returns the pixel size in m of the raster at location
+func rasterResolution(location string) (int, error) {
+	dataset, err := gdal.Open(location, gdal.ReadOnly)
+	if err != nil {
+		return 0, errors.New("Error opening Dataset: " + err.Error())
+	}
+	defer dataset.Close()
+	return int(math.Round(math.Abs(dataset.GeoTransform()[1]))), nil
+}
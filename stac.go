@@ -0,0 +1,736 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/julienschmidt/httprouter"
+	"github.com/paulsmith/gogeos/geos"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Version of the STAC specification implemented by /stac
+const stacVersion = "1.0.0"
+
+// Default and maximum number of items per page
+const (
+	stacDefaultLimit = 10
+	stacMaxLimit     = 1000
+)
+
+// Conformance classes implemented by /stac
+var stacConformance = []string{
+	"https://api.stacspec.org/v1.0.0/core",
+	"https://api.stacspec.org/v1.0.0/collections",
+	"https://api.stacspec.org/v1.0.0/ogcapi-features",
+	"https://api.stacspec.org/v1.0.0/item-search",
+	"http://www.opengis.net/spec/ogcapi-features-1/1.0/conf/core",
+	"http://www.opengis.net/spec/ogcapi-features-1/1.0/conf/geojson",
+}
+
+// Extensions used by STAC items
+var stacExtensions = []string{
+	"https://stac-extensions.github.io/eo/v1.0.0/schema.json",
+	"https://stac-extensions.github.io/sat/v1.0.0/schema.json",
+}
+
+// stacCollections lists all collections, one per processing level
+var stacCollections = []struct {
+	ID, Level, Title, Description string
+}{
+	{"sentinel-2-l1c", "L1C", "Sentinel-2 Level-1C", "Sentinel-2 top of atmosphere reflectance"},
+	{"sentinel-2-l2a", "L2A", "Sentinel-2 Level-2A", "Sentinel-2 bottom of atmosphere reflectance"},
+}
+
+// Response Schemata of /stac
+type stacLink struct {
+	Rel    string      `json:"rel"`
+	Href   string      `json:"href"`
+	Type   string      `json:"type,omitempty"`
+	Title  string      `json:"title,omitempty"`
+	Method string      `json:"method,omitempty"`
+	Body   interface{} `json:"body,omitempty"`
+	Merge  bool        `json:"merge,omitempty"`
+}
+
+type stacCatalog struct {
+	Type        string     `json:"type"`
+	StacVersion string     `json:"stac_version"`
+	ID          string     `json:"id"`
+	Title       string     `json:"title"`
+	Description string     `json:"description"`
+	ConformsTo  []string   `json:"conformsTo"`
+	Links       []stacLink `json:"links"`
+}
+
+type stacCollection struct {
+	Type        string     `json:"type"`
+	StacVersion string     `json:"stac_version"`
+	ID          string     `json:"id"`
+	Title       string     `json:"title"`
+	Description string     `json:"description"`
+	License     string     `json:"license"`
+	Extent      stacExtent `json:"extent"`
+	Links       []stacLink `json:"links"`
+}
+
+type stacExtent struct {
+	Spatial struct {
+		Bbox [][4]float64 `json:"bbox"`
+	} `json:"spatial"`
+	Temporal struct {
+		Interval [][2]*time.Time `json:"interval"`
+	} `json:"temporal"`
+}
+
+type stacItem struct {
+	Type           string                 `json:"type"`
+	StacVersion    string                 `json:"stac_version"`
+	StacExtensions []string               `json:"stac_extensions"`
+	ID             string                 `json:"id"`
+	Collection     string                 `json:"collection"`
+	Geometry       geoJSONGeometry        `json:"geometry"`
+	Bbox           [4]float64             `json:"bbox"`
+	Properties     map[string]interface{} `json:"properties"`
+	Assets         map[string]stacAsset   `json:"assets"`
+	Links          []stacLink             `json:"links"`
+}
+
+type stacAsset struct {
+	Href  string   `json:"href"`
+	Type  string   `json:"type"`
+	Title string   `json:"title,omitempty"`
+	Roles []string `json:"roles"`
+}
+
+type stacItemCollection struct {
+	Type           string     `json:"type"`
+	Features       []stacItem `json:"features"`
+	Links          []stacLink `json:"links"`
+	NumberMatched  int        `json:"numberMatched"`
+	NumberReturned int        `json:"numberReturned"`
+}
+
+// Request Schema of /stac/search
+type stacSearch struct {
+	Bbox        []float64        `json:"bbox,omitempty"`
+	Datetime    string           `json:"datetime,omitempty"`
+	Intersects  *geoJSONGeometry `json:"intersects,omitempty"`
+	Collections []string         `json:"collections,omitempty"`
+	IDs         []string         `json:"ids,omitempty"`
+	Limit       int              `json:"limit,omitempty"`
+	Token       string           `json:"token,omitempty"`
+
+	// Parsed by prepare
+	start, end *time.Time
+	geometry   *geos.Geometry
+}
+
+// StacLandingHandler returns the root catalog of the STAC API
+func StacLandingHandler(w http.ResponseWriter, r *http.Request) {
+	base := baseURL(r)
+	catalog := stacCatalog{
+		Type:        "Catalog",
+		StacVersion: stacVersion,
+		ID:          "skylax",
+		Title:       "Skylax",
+		Description: "Sentinel-2 products available on this server",
+		ConformsTo:  stacConformance,
+		Links: []stacLink{
+			{Rel: "self", Href: base + "/stac", Type: "application/json"},
+			{Rel: "root", Href: base + "/stac", Type: "application/json"},
+			{Rel: "conformance", Href: base + "/stac/conformance", Type: "application/json"},
+			{Rel: "data", Href: base + "/stac/collections", Type: "application/json"},
+			{Rel: "search", Href: base + "/stac/search", Type: "application/geo+json", Method: "GET"},
+			{Rel: "search", Href: base + "/stac/search", Type: "application/geo+json", Method: "POST"},
+		},
+	}
+	for _, c := range stacCollections {
+		catalog.Links = append(catalog.Links, stacLink{Rel: "child", Href: base + "/stac/collections/" + c.ID, Type: "application/json", Title: c.Title})
+	}
+	writeJSON(w, "application/json", catalog)
+}
+
+// StacConformanceHandler returns the conformance classes of the STAC API
+func StacConformanceHandler(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, "application/json", map[string][]string{"conformsTo": stacConformance})
+}
+
+// StacCollectionsHandler returns all collections
+func StacCollectionsHandler(w http.ResponseWriter, r *http.Request) {
+	products, err := Index.All()
+	if err != nil {
+		w.WriteHeader(500)
+		w.Write([]byte("Unable to read Index: " + err.Error()))
+		return
+	}
+	base := baseURL(r)
+	var collections []stacCollection
+	for _, c := range stacCollections {
+		collections = append(collections, stacCollectionOf(c.ID, products, base))
+	}
+	writeJSON(w, "application/json", map[string]interface{}{
+		"collections": collections,
+		"links": []stacLink{
+			{Rel: "self", Href: base + "/stac/collections", Type: "application/json"},
+			{Rel: "root", Href: base + "/stac", Type: "application/json"},
+		},
+	})
+}
+
+// StacCollectionHandler returns a single collection
+func StacCollectionHandler(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	id := ps.ByName("collection")
+	if stacCollectionLevel(id) == "" {
+		w.WriteHeader(404)
+		w.Write([]byte("Collection " + id + " not found"))
+		return
+	}
+	products, err := Index.All()
+	if err != nil {
+		w.WriteHeader(500)
+		w.Write([]byte("Unable to read Index: " + err.Error()))
+		return
+	}
+	writeJSON(w, "application/json", stacCollectionOf(id, products, baseURL(r)))
+}
+
+// StacItemsHandler returns the items of a collection, supporting the same query parameters as /stac/search
+func StacItemsHandler(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	id := ps.ByName("collection")
+	if stacCollectionLevel(id) == "" {
+		w.WriteHeader(404)
+		w.Write([]byte("Collection " + id + " not found"))
+		return
+	}
+	search, err := parseStacSearchQuery(r.URL.Query())
+	if err != nil {
+		w.WriteHeader(400)
+		w.Write([]byte(err.Error()))
+		return
+	}
+	search.Collections = []string{id}
+	writeStacSearch(w, r, search, baseURL(r)+"/stac/collections/"+id+"/items")
+}
+
+// StacItemHandler returns a single item
+func StacItemHandler(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	collection := ps.ByName("collection")
+	id := ps.ByName("item")
+	p, err := Index.Get(id + ".SAFE")
+	if err == nil && p == nil {
+		p, err = Index.Get(id)
+	}
+	if err != nil {
+		w.WriteHeader(500)
+		w.Write([]byte("Unable to read Index: " + err.Error()))
+		return
+	}
+	if p == nil || stacCollectionLevel(collection) != p.Level {
+		w.WriteHeader(404)
+		w.Write([]byte("Item " + id + " not found in collection " + collection))
+		return
+	}
+	item, err := stacItemOf(p, baseURL(r))
+	if err != nil {
+		w.WriteHeader(500)
+		w.Write([]byte("Unable to create Item: " + err.Error()))
+		return
+	}
+	writeJSON(w, "application/geo+json", item)
+}
+
+// StacSearchHandler searches items of all collections using query parameters (GET) or a JSON body (POST)
+func StacSearchHandler(w http.ResponseWriter, r *http.Request) {
+	defer Timetrack(time.Now(), "STAC Search ")
+	var search *stacSearch
+	var err error
+	if r.Method == "POST" {
+		search = &stacSearch{}
+		err = json.NewDecoder(r.Body).Decode(search)
+		if err != nil {
+			err = errors.New("Unable to parse request body: " + err.Error())
+		}
+	} else {
+		search, err = parseStacSearchQuery(r.URL.Query())
+	}
+	if err != nil {
+		w.WriteHeader(400)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	// Log request if verbose is set
+	if Verbose {
+		fmt.Print("Request to /stac/search with parameters: ")
+		fmt.Printf("%+v\n", *search)
+	}
+	writeStacSearch(w, r, search, baseURL(r)+"/stac/search")
+}
+
+// writeStacSearch runs search and writes the page of matching items including a link to the next page
+func writeStacSearch(w http.ResponseWriter, r *http.Request, search *stacSearch, self string) {
+	err := search.prepare()
+	if err != nil {
+		w.WriteHeader(400)
+		w.Write([]byte(err.Error()))
+		return
+	}
+	products, matched, next, err := search.run()
+	if err != nil {
+		w.WriteHeader(500)
+		w.Write([]byte("Unable to search Index: " + err.Error()))
+		return
+	}
+
+	base := baseURL(r)
+	response := stacItemCollection{
+		Type:           "FeatureCollection",
+		Features:       []stacItem{},
+		NumberMatched:  matched,
+		NumberReturned: len(products),
+		Links: []stacLink{
+			{Rel: "root", Href: base + "/stac", Type: "application/json"},
+		},
+	}
+	for _, p := range products {
+		item, err := stacItemOf(p, base)
+		if err != nil {
+			w.WriteHeader(500)
+			w.Write([]byte("Unable to create Item for " + p.Name + ": " + err.Error()))
+			return
+		}
+		response.Features = append(response.Features, item)
+	}
+
+	// Link next page with the same method the search was requested with
+	if next != "" {
+		if r.Method == "POST" {
+			response.Links = append(response.Links, stacLink{Rel: "next", Href: self, Type: "application/geo+json", Method: "POST", Body: map[string]string{"token": next}, Merge: true})
+		} else {
+			q := r.URL.Query()
+			q.Set("token", next)
+			response.Links = append(response.Links, stacLink{Rel: "next", Href: self + "?" + q.Encode(), Type: "application/geo+json", Method: "GET"})
+		}
+	}
+	writeJSON(w, "application/geo+json", response)
+}
+
+// parseStacSearchQuery parses the query parameters of a GET search
+func parseStacSearchQuery(q url.Values) (*stacSearch, error) {
+	search := &stacSearch{
+		Datetime: q.Get("datetime"),
+		Token:    q.Get("token"),
+	}
+	if q.Get("bbox") != "" {
+		for _, coordinate := range strings.Split(q.Get("bbox"), ",") {
+			value, err := strconv.ParseFloat(strings.TrimSpace(coordinate), 64)
+			if err != nil {
+				return nil, errors.New("Invalid coordinate in bbox: " + err.Error())
+			}
+			search.Bbox = append(search.Bbox, value)
+		}
+	}
+	if q.Get("intersects") != "" {
+		search.Intersects = &geoJSONGeometry{}
+		err := json.Unmarshal([]byte(q.Get("intersects")), search.Intersects)
+		if err != nil {
+			return nil, errors.New("Invalid GeoJSON in intersects: " + err.Error())
+		}
+	}
+	if q.Get("collections") != "" {
+		search.Collections = strings.Split(q.Get("collections"), ",")
+	}
+	if q.Get("ids") != "" {
+		search.IDs = strings.Split(q.Get("ids"), ",")
+	}
+	if q.Get("limit") != "" {
+		var err error
+		search.Limit, err = strconv.Atoi(q.Get("limit"))
+		if err != nil {
+			return nil, errors.New("Invalid limit: " + err.Error())
+		}
+	}
+	return search, nil
+}
+
+// prepare validates the search and parses its datetime and geometry
+func (s *stacSearch) prepare() error {
+	if s.Limit < 0 {
+		return errors.New("Limit must be positive")
+	}
+	if s.Limit == 0 {
+		s.Limit = stacDefaultLimit
+	}
+	if s.Limit > stacMaxLimit {
+		s.Limit = stacMaxLimit
+	}
+	for _, id := range s.Collections {
+		if stacCollectionLevel(id) == "" {
+			return errors.New("Unknown collection '" + id + "'")
+		}
+	}
+	if s.Token != "" && len(s.Token) < 11 {
+		return errors.New("Invalid token")
+	}
+
+	var err error
+	s.start, s.end, err = parseDatetimeInterval(s.Datetime)
+	if err != nil {
+		return err
+	}
+
+	// Only one of bbox and intersects may be used
+	if s.Bbox != nil && s.Intersects != nil {
+		return errors.New("Only one of bbox and intersects can be used")
+	}
+	if s.Bbox != nil {
+		var b [4]float64
+		switch len(s.Bbox) {
+		case 4:
+			copy(b[:], s.Bbox)
+		case 6:
+			b = [4]float64{s.Bbox[0], s.Bbox[1], s.Bbox[3], s.Bbox[4]}
+		default:
+			return errors.New("bbox needs 4 or 6 coordinates")
+		}
+		if b[0] > b[2] || b[1] > b[3] {
+			return errors.New("bbox minimum is larger than maximum")
+		}
+		s.geometry, err = bboxGeometry(b)
+		if err != nil {
+			return err
+		}
+	}
+	if s.Intersects != nil {
+		s.geometry, err = geometryFromGeoJSON(*s.Intersects)
+		if err != nil {
+			return errors.New("Invalid intersects geometry: " + err.Error())
+		}
+	}
+	return nil
+}
+
+// parseDatetimeInterval parses a single RFC 3339 datetime or an interval with optional open ends ("..")
+func parseDatetimeInterval(datetime string) (start, end *time.Time, err error) {
+	if datetime == "" {
+		return nil, nil, nil
+	}
+	parts := strings.Split(datetime, "/")
+	if len(parts) > 2 {
+		return nil, nil, errors.New("Invalid datetime interval '" + datetime + "'")
+	}
+	var times []*time.Time
+	for _, part := range parts {
+		if part == "" || part == ".." {
+			times = append(times, nil)
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, part)
+		if err != nil {
+			return nil, nil, errors.New("Invalid datetime: " + err.Error())
+		}
+		times = append(times, &t)
+	}
+	if len(times) == 1 {
+		return times[0], times[0], nil
+	}
+	if times[0] != nil && times[1] != nil && times[0].After(*times[1]) {
+		return nil, nil, errors.New("Start of datetime interval is after its end")
+	}
+	return times[0], times[1], nil
+}
+
+// run returns one page of matching products, the number of all matching products and the token of the next page
+func (s *stacSearch) run() (page []*Product, matched int, next string, err error) {
+	products, err := Index.All()
+	if err != nil {
+		return nil, 0, "", err
+	}
+	sort.Sort(Sentinel2Dataset(products))
+
+	// Products up to and including the token were returned on previous pages
+	var token *Product
+	if s.Token != "" {
+		token = &Product{Name: s.Token}
+	}
+	for _, p := range products {
+		match, err := s.matches(p)
+		if err != nil {
+			return nil, 0, "", err
+		}
+		if !match {
+			continue
+		}
+		matched++
+		if token != nil && !Sentinel2Dataset([]*Product{token, p}).Less(0, 1) {
+			continue
+		}
+		if len(page) < s.Limit {
+			page = append(page, p)
+		} else if next == "" {
+			next = page[len(page)-1].Name
+		}
+	}
+	return page, matched, next, nil
+}
+
+// matches reports whether p matches all criteria of the search
+func (s *stacSearch) matches(p *Product) (bool, error) {
+	if len(s.Collections) > 0 {
+		found := false
+		for _, id := range s.Collections {
+			found = found || stacCollectionLevel(id) == p.Level
+		}
+		if !found {
+			return false, nil
+		}
+	}
+	if len(s.IDs) > 0 {
+		found := false
+		for _, id := range s.IDs {
+			found = found || id == stacItemID(p)
+		}
+		if !found {
+			return false, nil
+		}
+	}
+	if s.start != nil && p.SensingTime.Before(*s.start) {
+		return false, nil
+	}
+	if s.end != nil && p.SensingTime.After(*s.end) {
+		return false, nil
+	}
+	if s.geometry != nil {
+		footprint, err := geos.FromWKT(p.Footprint)
+		if err != nil {
+			return false, err
+		}
+		return footprint.Intersects(s.geometry)
+	}
+	return true, nil
+}
+
+// stacCollectionLevel returns the processing level of a collection or "" if it does not exist
+func stacCollectionLevel(id string) string {
+	for _, c := range stacCollections {
+		if c.ID == id {
+			return c.Level
+		}
+	}
+	return ""
+}
+
+// stacCollectionID returns the collection of products with given processing level
+func stacCollectionID(level string) string {
+	for _, c := range stacCollections {
+		if c.Level == level {
+			return c.ID
+		}
+	}
+	return ""
+}
+
+// stacItemID returns the item id of a product, its name without the .SAFE suffix
+func stacItemID(p *Product) string {
+	return strings.TrimSuffix(p.Name, ".SAFE")
+}
+
+// stacCollectionOf describes the collection with given id, its temporal extent is computed from products
+func stacCollectionOf(id string, products []*Product, base string) stacCollection {
+	collection := stacCollection{
+		Type:        "Collection",
+		StacVersion: stacVersion,
+		ID:          id,
+		License:     "proprietary",
+		Links: []stacLink{
+			{Rel: "self", Href: base + "/stac/collections/" + id, Type: "application/json"},
+			{Rel: "root", Href: base + "/stac", Type: "application/json"},
+			{Rel: "parent", Href: base + "/stac", Type: "application/json"},
+			{Rel: "items", Href: base + "/stac/collections/" + id + "/items", Type: "application/geo+json"},
+			{Rel: "license", Href: "https://sentinel.esa.int/documents/247904/690755/Sentinel_Data_Legal_Notice", Title: "Legal notice on the use of Copernicus Sentinel Data"},
+		},
+	}
+	level := stacCollectionLevel(id)
+	for _, c := range stacCollections {
+		if c.ID == id {
+			collection.Title = c.Title
+			collection.Description = c.Description
+		}
+	}
+
+	// Get temporal extent of all products of the collection
+	var first, last *time.Time
+	for _, p := range products {
+		if p.Level != level {
+			continue
+		}
+		sensingTime := p.SensingTime
+		if first == nil || sensingTime.Before(*first) {
+			first = &sensingTime
+		}
+		if last == nil || sensingTime.After(*last) {
+			last = &sensingTime
+		}
+	}
+	collection.Extent.Spatial.Bbox = [][4]float64{{-180, -90, 180, 90}}
+	collection.Extent.Temporal.Interval = [][2]*time.Time{{first, last}}
+	return collection
+}
+
+// stacItemOf describes a product as STAC item with assets pointing at its image files
+func stacItemOf(p *Product, base string) (stacItem, error) {
+	footprint, err := geos.FromWKT(p.Footprint)
+	if err != nil {
+		return stacItem{}, err
+	}
+	geometry, err := geoJSONFromGeometry(footprint)
+	if err != nil {
+		return stacItem{}, err
+	}
+	bbox, err := geometryBounds(footprint)
+	if err != nil {
+		return stacItem{}, err
+	}
+
+	id := stacItemID(p)
+	collection := stacCollectionID(p.Level)
+	item := stacItem{
+		Type:           "Feature",
+		StacVersion:    stacVersion,
+		StacExtensions: stacExtensions,
+		ID:             id,
+		Collection:     collection,
+		Geometry:       geometry,
+		Bbox:           bbox,
+		Properties: map[string]interface{}{
+			"datetime":      p.SensingTime,
+			"created":       p.GenerationTime,
+			"platform":      stacPlatform(p),
+			"constellation": "sentinel-2",
+			"instruments":   []string{"msi"},
+		},
+		Assets: make(map[string]stacAsset),
+		Links: []stacLink{
+			{Rel: "self", Href: base + "/stac/collections/" + collection + "/items/" + id, Type: "application/geo+json"},
+			{Rel: "parent", Href: base + "/stac/collections/" + collection, Type: "application/json"},
+			{Rel: "collection", Href: base + "/stac/collections/" + collection, Type: "application/json"},
+			{Rel: "root", Href: base + "/stac", Type: "application/json"},
+		},
+	}
+
+	if p.CloudCover != nil {
+		item.Properties["eo:cloud_cover"] = *p.CloudCover
+	}
+	if p.RelativeOrbit != 0 {
+		item.Properties["sat:relative_orbit"] = p.RelativeOrbit
+	}
+
+	// Add image files as assets
+	for _, image := range p.Images {
+		key := stacAssetKey(image)
+		role := "data"
+		if strings.HasPrefix(key, "TCI") {
+			role = "visual"
+		}
+		item.Assets[key] = stacAsset{
+			Href:  base + "/products/" + p.Name + "/" + key,
+			Type:  "image/jp2",
+			Title: key,
+			Roles: []string{role},
+		}
+	}
+	item.Assets["metadata"] = stacAsset{
+		Href:  base + "/products/" + p.Name + "/metadata",
+		Type:  "application/xml",
+		Title: "Product metadata",
+		Roles: []string{"metadata"},
+	}
+	return item, nil
+}
+
+// StacAssetHandler serves the file of a single asset of an indexed product, other files of DataSource are not exposed
+func StacAssetHandler(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	p, err := Index.Get(ps.ByName("product"))
+	if err != nil {
+		w.WriteHeader(500)
+		w.Write([]byte("Unable to read Index: " + err.Error()))
+		return
+	}
+	if p == nil {
+		w.WriteHeader(404)
+		w.Write([]byte("Cannot find Dataset " + ps.ByName("product")))
+		return
+	}
+	location := stacAssetLocation(p, ps.ByName("asset"))
+	if location == "" {
+		w.WriteHeader(404)
+		w.Write([]byte("Cannot find asset " + ps.ByName("asset") + " of Dataset " + p.Name))
+		return
+	}
+	if strings.HasSuffix(location, ".jp2") {
+		w.Header().Set("Content-Type", "image/jp2")
+	}
+	http.ServeFile(w, r, location)
+}
+
+// stacAssetLocation returns the file of the asset with given key of product p or an empty string if there is none
+func stacAssetLocation(p *Product, key string) string {
+	if key == "metadata" {
+		return DataSource + p.Name + "/MTD_MSI" + p.Level + ".xml"
+	}
+	for _, image := range p.Images {
+		if stacAssetKey(image) == key {
+			return DataSource + p.Name + "/" + p.Granule + image
+		}
+	}
+	return ""
+}
+
+// stacAssetKey returns the band and resolution of an image file, e.g. B04 or B04_10m
+func stacAssetKey(image string) string {
+	name := strings.TrimSuffix(image[strings.LastIndex(image, "/")+1:], ".jp2")
+	parts := strings.Split(name, "_")
+
+	// Band names follow the sensing time like 20180101T103421
+	for i, part := range parts {
+		if len(part) == 15 && part[8] == 'T' {
+			return strings.Join(parts[i+1:], "_")
+		}
+	}
+	return name
+}
+
+// stacPlatform returns the platform of a product like sentinel-2a
+func stacPlatform(p *Product) string {
+	return "sentinel-" + strings.ToLower(strings.TrimPrefix(p.Platform, "S"))
+}
+
+// baseURL returns scheme and host the request was sent to
+func baseURL(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	if proto := r.Header.Get("X-Forwarded-Proto"); proto != "" {
+		scheme = proto
+	}
+	return scheme + "://" + r.Host
+}
+
+// writeJSON encodes v as JSON response with given content type
+func writeJSON(w http.ResponseWriter, contentType string, v interface{}) {
+	response, err := json.Marshal(v)
+	if err != nil {
+		w.WriteHeader(500)
+		w.Write([]byte("Unable to encode response: " + err.Error()))
+		return
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Write(response)
+}
@@ -0,0 +1,261 @@
+package main
+
+import (
+	"errors"
+	"math"
+)
+
+// Number of bins used internally to compute percentiles
+const histogramBins = 65536
+
+// pixelWindow is a rectangular part of a square raster given as fractions of its size
+type pixelWindow struct {
+	X0, Y0, X1, Y1 float64
+}
+
+// bounds returns the pixel bounds of the window in a square raster with given rowsize.
+// A nil window covers the whole raster.
+func (w *pixelWindow) bounds(rowsize int) (x0, y0, x1, y1 int) {
+	if w == nil {
+		return 0, 0, rowsize, rowsize
+	}
+	clamp := func(v float64) int {
+		return int(math.Max(0, math.Min(float64(rowsize), math.Floor(v*float64(rowsize)+0.5))))
+	}
+	return clamp(w.X0), clamp(w.Y0), clamp(w.X1), clamp(w.Y1)
+}
+
+// bandStatistics summarizes all valid values of a band
+type bandStatistics struct {
+	Count  int
+	Min    float64
+	Max    float64
+	Mean   float64
+	StdDev float64
+
+	// histogram with histogramBins equally sized bins between Min and Max
+	histogram []int
+}
+
+// statisticsUint16 computes statistics of a square band inside window, ignoring nodata values of 0
+func statisticsUint16(data []uint16, window *pixelWindow) *bandStatistics {
+	rowsize := int(math.Sqrt(float64(len(data))))
+	x0, y0, x1, y1 := window.bounds(rowsize)
+
+	// Count values directly as there is one bin per possible value
+	counts := make([]int, math.MaxUint16+1)
+	for row := y0; row < y1; row++ {
+		for _, v := range data[row*rowsize+x0 : row*rowsize+x1] {
+			counts[v]++
+		}
+	}
+	counts[0] = 0
+
+	s := &bandStatistics{Min: math.NaN(), Max: math.NaN()}
+	var sum, sumsq float64
+	for v, c := range counts {
+		if c == 0 {
+			continue
+		}
+		if s.Count == 0 {
+			s.Min = float64(v)
+		}
+		s.Max = float64(v)
+		s.Count += c
+		sum += float64(c) * float64(v)
+		sumsq += float64(c) * float64(v) * float64(v)
+	}
+	s.finish(sum, sumsq)
+	if s.Count > 0 {
+		s.histogram = counts[int(s.Min) : int(s.Max)+1]
+	}
+	return s
+}
+
+// statisticsFloat32 computes statistics of a square band inside window, ignoring NaN values
+func statisticsFloat32(data []float32, window *pixelWindow) *bandStatistics {
+	rowsize := int(math.Sqrt(float64(len(data))))
+	x0, y0, x1, y1 := window.bounds(rowsize)
+
+	s := &bandStatistics{Min: math.Inf(1), Max: math.Inf(-1)}
+	var sum, sumsq float64
+	for row := y0; row < y1; row++ {
+		for _, v := range data[row*rowsize+x0 : row*rowsize+x1] {
+			c := float64(v)
+			if math.IsNaN(c) {
+				continue
+			}
+			s.Count++
+			sum += c
+			sumsq += c * c
+			s.Min = math.Min(s.Min, c)
+			s.Max = math.Max(s.Max, c)
+		}
+	}
+	if s.Count == 0 {
+		s.Min, s.Max = math.NaN(), math.NaN()
+		s.finish(0, 0)
+		return s
+	}
+	s.finish(sum, sumsq)
+
+	// Second pass to fill histogram
+	s.histogram = make([]int, histogramBins)
+	for row := y0; row < y1; row++ {
+		for _, v := range data[row*rowsize+x0 : row*rowsize+x1] {
+			if !math.IsNaN(float64(v)) {
+				s.histogram[s.bin(float64(v))]++
+			}
+		}
+	}
+	return s
+}
+
+// finish computes mean and standard deviation from sums
+func (s *bandStatistics) finish(sum, sumsq float64) {
+	if s.Count == 0 {
+		s.Mean, s.StdDev = math.NaN(), math.NaN()
+		return
+	}
+	s.Mean = sum / float64(s.Count)
+	s.StdDev = math.Sqrt(math.Max(0, sumsq/float64(s.Count)-s.Mean*s.Mean))
+}
+
+// binWidth returns the value range covered by a single histogram bin
+func (s *bandStatistics) binWidth() float64 {
+	if len(s.histogram) <= 1 || s.Max <= s.Min {
+		return 1
+	}
+	return (s.Max - s.Min) / float64(len(s.histogram)-1)
+}
+
+// bin returns the histogram bin of value v
+func (s *bandStatistics) bin(v float64) int {
+	b := int((v - s.Min) / s.binWidth())
+	if b < 0 {
+		return 0
+	}
+	if b >= len(s.histogram) {
+		return len(s.histogram) - 1
+	}
+	return b
+}
+
+// percentile returns the value below which p percent of all values fall
+func (s *bandStatistics) percentile(p float64) float64 {
+	if s.Count == 0 {
+		return math.NaN()
+	}
+	target := int(math.Ceil(p / 100 * float64(s.Count)))
+	cumulative := 0
+	for b, c := range s.histogram {
+		cumulative += c
+		if cumulative >= target && cumulative > 0 {
+			return s.Min + float64(b)*s.binWidth()
+		}
+	}
+	return s.Max
+}
+
+// cdf returns the cumulative distribution of all histogram bins
+func (s *bandStatistics) cdf() []float64 {
+	cdf := make([]float64, len(s.histogram))
+	cumulative := 0
+	for b, c := range s.histogram {
+		cumulative += c
+		cdf[b] = float64(cumulative) / float64(s.Count)
+	}
+	return cdf
+}
+
+// Supported contrast stretch modes
+const (
+	StretchMinMax     = "minmax"
+	StretchPercentile = "percentile"
+	StretchStdDev     = "stddev"
+	StretchHistEq     = "histeq"
+)
+
+// stretchLimits is the value range of a band mapped to the output range
+type stretchLimits struct {
+	Min float64 `json:"min"`
+	Max float64 `json:"max"`
+}
+
+// contrastStretch maps band values to 8-bit output values between 1 and 255
+type contrastStretch struct {
+	Limits stretchLimits
+	stats  *bandStatistics
+	// cumulative distribution, only set for histogram equalization
+	cdf []float64
+}
+
+// validStretch checks if mode is a supported stretch mode
+func validStretch(mode string) error {
+	switch mode {
+	case StretchMinMax, StretchPercentile, StretchStdDev, StretchHistEq:
+		return nil
+	}
+	return errors.New("Unknown stretch mode '" + mode + "'")
+}
+
+// newContrastStretch computes a stretch of given mode from band statistics
+func newContrastStretch(mode string, stats *bandStatistics) (*contrastStretch, error) {
+	err := validStretch(mode)
+	if err != nil {
+		return nil, err
+	}
+	if stats.Count == 0 {
+		return nil, errors.New("Band has no valid values to compute stretch from")
+	}
+	s := &contrastStretch{stats: stats}
+	switch mode {
+	case StretchMinMax, StretchHistEq:
+		s.Limits = stretchLimits{stats.Min, stats.Max}
+	case StretchPercentile:
+		s.Limits = stretchLimits{stats.percentile(2), stats.percentile(98)}
+	case StretchStdDev:
+		s.Limits = stretchLimits{
+			math.Max(stats.Min, stats.Mean-2*stats.StdDev),
+			math.Min(stats.Max, stats.Mean+2*stats.StdDev),
+		}
+	}
+	if mode == StretchHistEq {
+		s.cdf = stats.cdf()
+	}
+	return s, nil
+}
+
+// apply maps v to the output range 1-255, clamping values outside of the limits
+func (s *contrastStretch) apply(v float64) byte {
+	var t float64
+	if s.cdf != nil {
+		t = s.cdf[s.stats.bin(v)]
+	} else if s.Limits.Max > s.Limits.Min {
+		t = (v - s.Limits.Min) / (s.Limits.Max - s.Limits.Min)
+	}
+	t = math.Max(0, math.Min(1, t))
+	return byte(1 + t*254)
+}
+
+// lut returns a lookup table applying the stretch to all uint16 values, keeping 0 as nodata
+func (s *contrastStretch) lut() []byte {
+	lut := make([]byte, math.MaxUint16+1)
+	for v := 1; v < len(lut); v++ {
+		lut[v] = s.apply(float64(v))
+	}
+	return lut
+}
+
+// binned aggregates the histogram into n equally sized bins between Min and Max
+func (s *bandStatistics) binned(n int) []int {
+	counts := make([]int, n)
+	if s.Count == 0 {
+		return counts
+	}
+	for b, c := range s.histogram {
+		i := b * n / len(s.histogram)
+		counts[i] += c
+	}
+	return counts
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Statistics caches computed band statistics by band file location
+var Statistics *lruCache
+
+// Percentiles returned by /stats
+var statsPercentiles = []float64{2, 5, 25, 50, 75, 95, 98}
+
+// Response Schema of /stats
+type statsResponse struct {
+	Band        string             `json:"band"`
+	Count       int                `json:"count"`
+	Min         float64            `json:"min"`
+	Max         float64            `json:"max"`
+	Mean        float64            `json:"mean"`
+	StdDev      float64            `json:"stddev"`
+	Percentiles map[string]float64 `json:"percentiles"`
+	Histogram   histogramResponse  `json:"histogram"`
+}
+
+type histogramResponse struct {
+	Min    float64 `json:"min"`
+	Max    float64 `json:"max"`
+	Counts []int   `json:"counts"`
+}
+
+// StatsHandler returns statistics and a binned histogram of a single band
+func StatsHandler(w http.ResponseWriter, r *http.Request) {
+	defer Timetrack(time.Now(), "Stats ")
+	q := r.URL.Query()
+	datasetname := q.Get("d")
+	bandname := q.Get("b")
+
+	// Log request if verbose is set
+	if Verbose {
+		fmt.Print("Request to /stats with parameters: ")
+		fmt.Println(q)
+	}
+
+	if datasetname == "" || bandname == "" {
+		w.WriteHeader(400)
+		w.Write([]byte("Parameters 'd' and 'b' are required"))
+		return
+	}
+
+	// Get number of histogram bins
+	bins := 256
+	if q.Get("bins") != "" {
+		var err error
+		bins, err = strconv.Atoi(q.Get("bins"))
+		if err != nil || bins < 1 || bins > histogramBins {
+			w.WriteHeader(400)
+			w.Write([]byte("Invalid number of bins"))
+			return
+		}
+	}
+
+	// Get band file
+	location, err := bandLocation(datasetname, bandname)
+	if err != nil {
+		w.WriteHeader(404)
+		w.Write([]byte("Cannot find Band in Dataset: " + err.Error()))
+		return
+	}
+
+	stats, err := bandStatisticsOf(location)
+	if err != nil {
+		w.WriteHeader(500)
+		w.Write([]byte("Unable to compute statistics: " + err.Error()))
+		return
+	}
+
+	// Assemble response
+	response := statsResponse{
+		Band:        bandname,
+		Count:       stats.Count,
+		Min:         finiteOrZero(stats.Min),
+		Max:         finiteOrZero(stats.Max),
+		Mean:        finiteOrZero(stats.Mean),
+		StdDev:      finiteOrZero(stats.StdDev),
+		Percentiles: make(map[string]float64),
+		Histogram: histogramResponse{
+			Min:    finiteOrZero(stats.Min),
+			Max:    finiteOrZero(stats.Max),
+			Counts: stats.binned(bins),
+		},
+	}
+	for _, p := range statsPercentiles {
+		response.Percentiles[strconv.FormatFloat(p, 'f', -1, 64)] = finiteOrZero(stats.percentile(p))
+	}
+
+	statsjson, err := json.Marshal(response)
+	if err != nil {
+		w.WriteHeader(500)
+		w.Write([]byte("Unable to encode statistics: " + err.Error()))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(statsjson)
+}
+
+// bandStatisticsOf returns the statistics of the band file at location, computing them only once
+func bandStatisticsOf(location string) (*bandStatistics, error) {
+	if stats, ok := Statistics.Get(location); ok {
+		return stats.(*bandStatistics), nil
+	}
+	data, err := readBandData(location)
+	if err != nil {
+		return nil, err
+	}
+	stats := statisticsUint16(data, nil)
+	Statistics.Add(location, stats)
+	return stats, nil
+}
+
+// finiteOrZero replaces NaN and infinite values as they cannot be encoded as JSON
+func finiteOrZero(v float64) float64 {
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return 0
+	}
+	return v
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+// squareUint16 returns a square raster holding values, padded with nodata
+func squareUint16(values ...uint16) []uint16 {
+	rowsize := int(math.Ceil(math.Sqrt(float64(len(values)))))
+	data := make([]uint16, rowsize*rowsize)
+	copy(data, values)
+	return data
+}
+
+// repeatUint16 returns value n times
+func repeatUint16(value uint16, n int) []uint16 {
+	values := make([]uint16, n)
+	for i := range values {
+		values[i] = value
+	}
+	return values
+}
+
+// rampUint16 returns all values from 1 to n
+func rampUint16(n int) []uint16 {
+	values := make([]uint16, n)
+	for i := range values {
+		values[i] = uint16(i + 1)
+	}
+	return values
+}
+
+func TestNewContrastStretch(t *testing.T) {
+	// Mean 50 and standard deviation 8
+	spread := append(repeatUint16(50, 96), 10, 10, 90, 90)
+
+	tests := []struct {
+		name string
+		mode string
+		data []uint16
+		want stretchLimits
+	}{
+		{"minmax", StretchMinMax, rampUint16(100), stretchLimits{1, 100}},
+		{"minmax ignores nodata", StretchMinMax, squareUint16(7, 3, 9), stretchLimits{3, 9}},
+		{"percentile", StretchPercentile, rampUint16(100), stretchLimits{2, 98}},
+		{"stddev", StretchStdDev, spread, stretchLimits{34, 66}},
+		{"stddev clamped to range", StretchStdDev, rampUint16(100), stretchLimits{1, 100}},
+		{"histeq", StretchHistEq, rampUint16(100), stretchLimits{1, 100}},
+	}
+	for _, test := range tests {
+		stretch, err := newContrastStretch(test.mode, statisticsUint16(squareUint16(test.data...), nil))
+		if err != nil {
+			t.Errorf("%s: newContrastStretch failed: %v", test.name, err)
+			continue
+		}
+		if math.Abs(stretch.Limits.Min-test.want.Min) > 1e-9 || math.Abs(stretch.Limits.Max-test.want.Max) > 1e-9 {
+			t.Errorf("%s: limits = %v, want %v", test.name, stretch.Limits, test.want)
+		}
+	}
+}
+
+func TestNewContrastStretchErrors(t *testing.T) {
+	empty := statisticsUint16(make([]uint16, 16), nil)
+	if empty.Count != 0 || !math.IsNaN(empty.percentile(50)) {
+		t.Errorf("statistics of nodata = %+v, want no values", empty)
+	}
+	if _, err := newContrastStretch(StretchMinMax, empty); err == nil {
+		t.Error("newContrastStretch of empty histogram succeeded, want error")
+	}
+	if _, err := newContrastStretch("gamma", statisticsUint16(rampUint16(4), nil)); err == nil {
+		t.Error("newContrastStretch with unknown mode succeeded, want error")
+	}
+}
+
+func TestContrastStretchApply(t *testing.T) {
+	// Most values are dark, few are bright
+	skewed := append(repeatUint16(10, 90), repeatUint16(100, 10)...)
+
+	tests := []struct {
+		name   string
+		mode   string
+		data   []uint16
+		values []float64
+		want   []byte
+	}{
+		{"linear", StretchMinMax, rampUint16(100), []float64{1, 50.5, 100}, []byte{1, 128, 255}},
+		{"clamped", StretchMinMax, rampUint16(100), []float64{-5, 0, 101, 65535}, []byte{1, 1, 255, 255}},
+		{"histeq uniform", StretchHistEq, rampUint16(100), []float64{1, 50, 100}, []byte{3, 128, 255}},
+		{"histeq skewed", StretchHistEq, skewed, []float64{10, 50, 100}, []byte{229, 229, 255}},
+		{"histeq clamped", StretchHistEq, skewed, []float64{0, 1000}, []byte{229, 255}},
+	}
+	for _, test := range tests {
+		stretch, err := newContrastStretch(test.mode, statisticsUint16(squareUint16(test.data...), nil))
+		if err != nil {
+			t.Errorf("%s: newContrastStretch failed: %v", test.name, err)
+			continue
+		}
+		for i, v := range test.values {
+			if got := stretch.apply(v); got != test.want[i] {
+				t.Errorf("%s: apply(%v) = %v, want %v", test.name, v, got, test.want[i])
+			}
+		}
+	}
+}
+
+func TestContrastStretchLut(t *testing.T) {
+	stretch := &contrastStretch{Limits: stretchLimits{1000, 3000}}
+	lut := stretch.lut()
+	tests := []struct {
+		value int
+		want  byte
+	}{
+		{0, 0},
+		{1, 1},
+		{1000, 1},
+		{2000, 128},
+		{3000, 255},
+		{math.MaxUint16, 255},
+	}
+	for _, test := range tests {
+		if lut[test.value] != test.want {
+			t.Errorf("lut[%v] = %v, want %v", test.value, lut[test.value], test.want)
+		}
+	}
+
+	// Limits without range map all values to the lowest output value
+	flat := (&contrastStretch{Limits: stretchLimits{5, 5}}).lut()
+	if flat[5] != 1 || flat[100] != 1 {
+		t.Errorf("lut of empty range = %v, %v, want 1, 1", flat[5], flat[100])
+	}
+}
+
+func TestPercentile(t *testing.T) {
+	stats := statisticsUint16(squareUint16(rampUint16(100)...), nil)
+	for _, test := range []struct{ p, want float64 }{
+		{0, 1},
+		{2, 2},
+		{50, 50},
+		{98, 98},
+		{100, 100},
+	} {
+		if got := stats.percentile(test.p); got != test.want {
+			t.Errorf("percentile(%v) = %v, want %v", test.p, got, test.want)
+		}
+	}
+}
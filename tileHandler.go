@@ -0,0 +1,223 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/gorilla/schema"
+	"github.com/julienschmidt/httprouter"
+	"image"
+	"image/color"
+	"image/png"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Tiles caches rendered tiles by dataset, tile and style parameters
+var Tiles *lruCache
+
+// emptyTile is served for tiles without data
+var emptyTile []byte
+
+func init() {
+	var b bytes.Buffer
+	png.Encode(&b, image.NewRGBA(image.Rect(0, 0, tileSize, tileSize)))
+	emptyTile = b.Bytes()
+}
+
+// TileHandler renders single TMS tiles directly from the source Dataset
+func TileHandler(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	defer Timetrack(time.Now(), "Tile ")
+	q := r.URL.Query()
+
+	// Log request if verbose is set
+	if Verbose {
+		fmt.Print("Request to " + r.URL.Path + " with parameters: ")
+		fmt.Println(q)
+	}
+
+	// Parse tile coordinates
+	dataset := ps.ByName("dataset")
+	z, err := strconv.Atoi(ps.ByName("z"))
+	if err != nil {
+		w.WriteHeader(400)
+		w.Write([]byte("Unable to parse zoom level: " + err.Error()))
+		return
+	}
+	x, err := strconv.Atoi(ps.ByName("x"))
+	if err != nil {
+		w.WriteHeader(400)
+		w.Write([]byte("Unable to parse tile column: " + err.Error()))
+		return
+	}
+	y, err := strconv.Atoi(strings.TrimSuffix(ps.ByName("y"), ".png"))
+	if err != nil {
+		w.WriteHeader(400)
+		w.Write([]byte("Unable to parse tile row: " + err.Error()))
+		return
+	}
+
+	// Serve from cache if tile was already rendered with same parameters
+	key := r.URL.Path + "?" + q.Encode()
+	if tile, ok := Tiles.Get(key); ok {
+		writeTileResponse(w, tile.([]byte))
+		return
+	}
+
+	// Parse style parameters to options struct
+	var options options
+	decoder := schema.NewDecoder()
+	decoder.IgnoreUnknownKeys(true)
+	err = decoder.Decode(&options, q)
+	if err != nil {
+		w.WriteHeader(400)
+		w.Write([]byte("Unable to parse parameters: " + err.Error()))
+		return
+	}
+
+	// Validate colormap, an unknown colormap is not a server error
+	_, err = optionalColormap(options.Cmap)
+	if err != nil {
+		w.WriteHeader(400)
+		w.Write([]byte("Unable to parse colormap: " + err.Error()))
+		return
+	}
+
+	renderer, err := newTileRenderer(dataset, options)
+	if err != nil {
+		if err == ErrBandNotFound {
+			w.WriteHeader(404)
+		} else {
+			w.WriteHeader(500)
+		}
+		w.Write([]byte("Unable to open Dataset: " + err.Error()))
+		return
+	}
+	defer renderer.Close()
+
+	img, err := renderer.renderTile(z, x, y)
+	if err != nil {
+		w.WriteHeader(500)
+		w.Write([]byte("Unable to render tile: " + err.Error()))
+		return
+	}
+
+	// Encode tile
+	tile := emptyTile
+	if img != nil {
+		var b bytes.Buffer
+		err = png.Encode(&b, img)
+		if err != nil {
+			w.WriteHeader(500)
+			w.Write([]byte("Unable to encode tile: " + err.Error()))
+			return
+		}
+		tile = b.Bytes()
+	}
+	Tiles.Add(key, tile)
+	writeTileResponse(w, tile)
+}
+
+// writeTileResponse writes a PNG tile with default 200 OK Status Code
+func writeTileResponse(w http.ResponseWriter, tile []byte) {
+	w.Header().Set("Content-Type", "image/png")
+	w.Write(tile)
+}
+
+// newTileRenderer creates a renderer for the bands of dataset selected in options
+func newTileRenderer(dataset string, options options) (*rasterRenderer, error) {
+	// True Color Images are already 8-bit
+	if options.TCI {
+		file, err := bandLocation(dataset, "TCI")
+		if err != nil {
+			return nil, err
+		}
+		return newRasterRenderer([]string{file, file, file}, []int{1, 2, 3}, byteColors)
+	}
+
+	var bands []string
+	var limits [][2]float64
+	if options.Rgbbool {
+		bands = []string{options.Rcn, options.Gcn, options.Bcn}
+		limits = [][2]float64{
+			{options.Rcmin, options.Rcmax},
+			{options.Gcmin, options.Gcmax},
+			{options.Bcmin, options.Bcmax},
+		}
+	} else {
+		bands = []string{options.Gsc}
+		limits = [][2]float64{{options.Greymin, options.Greymax}}
+	}
+
+	files := make([]string, len(bands))
+	for i := range bands {
+		var err error
+		files[i], err = bandLocation(dataset, bands[i])
+		if err != nil {
+			return nil, err
+		}
+	}
+	colorize := stretchColors(limits)
+
+	// Color single bands by colormap if requested
+	if !options.Rgbbool && options.Cmap != "" {
+		palette, err := parseColormap(options.Cmap)
+		if err != nil {
+			return nil, err
+		}
+		colors := palette.palette()
+		grey := colorize
+		colorize = func(values []uint16) color.RGBA {
+			c := grey(values)
+			if c.A == 0 {
+				return c
+			}
+			// 0 denotes nodata in palette
+			if c.R == 0 {
+				c.R = 1
+			}
+			return colors[c.R]
+		}
+	}
+	return newRasterRenderer(files, firstBands(len(files)), colorize)
+}
+
+// firstBands returns a band map selecting the first band of n single band files
+func firstBands(n int) []int {
+	bands := make([]int, n)
+	for i := range bands {
+		bands[i] = 1
+	}
+	return bands
+}
+
+// stretchColors returns a colorizer linearly stretching each channel between its limits.
+// Values of 0 are treated as nodata.
+func stretchColors(limits [][2]float64) func(values []uint16) color.RGBA {
+	stretch := func(value uint16, limit [2]float64) uint8 {
+		v := (float64(value) - limit[0]) / (limit[1] - limit[0])
+		if v <= 0 || limit[1] <= limit[0] {
+			return 0
+		}
+		if v >= 1 {
+			return 255
+		}
+		return uint8(v * 255)
+	}
+	return func(values []uint16) color.RGBA {
+		if values[0] == 0 {
+			return color.RGBA{}
+		}
+		if len(values) == 1 {
+			v := stretch(values[0], limits[0])
+			return color.RGBA{v, v, v, 255}
+		}
+		return color.RGBA{
+			stretch(values[0], limits[0]),
+			stretch(values[1], limits[1]),
+			stretch(values[2], limits[2]),
+			255,
+		}
+	}
+}
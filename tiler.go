@@ -0,0 +1,387 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"github.com/ling-js/go-gdal"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+	"os"
+	"strconv"
+	"time"
+)
+
+// MinZoom command line Parameter
+var MinZoom = 4
+
+// MaxZoom command line Parameter
+var MaxZoom = 12
+
+// tileRenderer renders single tiles of a Web Mercator TMS pyramid
+type tileRenderer interface {
+	// bounds returns the extent of the renderable data in lon/lat
+	bounds() (west, south, east, north float64)
+	// renderTile renders TMS tile z/x/y. Returns nil if the tile holds no data
+	renderTile(z, x, y int) (*image.RGBA, error)
+}
+
+// rasterLayer is a single band of a dataset used as input for rendering
+type rasterLayer struct {
+	dataset *gdal.Dataset
+	band    int
+	inverse [6]float64
+	width   int
+	height  int
+}
+
+// rasterRenderer renders tiles from one or more raster bands sharing a CRS.
+// Data is read per tile via windowed reads so whole rasters never need to be kept in memory.
+type rasterRenderer struct {
+	layers     []rasterLayer
+	projection *projection
+	colorize   func(values []uint16) color.RGBA
+	extent     [4]float64
+}
+
+// newRasterRenderer opens the given files and uses band bands[i] of files[i] as input channel i
+func newRasterRenderer(files []string, bands []int, colorize func(values []uint16) color.RGBA) (*rasterRenderer, error) {
+	r := &rasterRenderer{colorize: colorize}
+	opened := make(map[string]*gdal.Dataset)
+	for i := range files {
+		dataset, ok := opened[files[i]]
+		if !ok {
+			var err error
+			dataset, err = gdal.Open(files[i], gdal.ReadOnly)
+			if err != nil {
+				r.Close()
+				return nil, errors.New("Error opening Dataset " + files[i] + ": " + err.Error())
+			}
+			opened[files[i]] = dataset
+		}
+		inverse, err := invertGeoTransform(dataset.GeoTransform())
+		if err != nil {
+			r.Close()
+			return nil, err
+		}
+		r.layers = append(r.layers, rasterLayer{
+			dataset: dataset,
+			band:    bands[i],
+			inverse: inverse,
+			width:   dataset.RasterXSize(),
+			height:  dataset.RasterYSize(),
+		})
+	}
+	if len(r.layers) == 0 {
+		return nil, errors.New("No input bands supplied")
+	}
+
+	// All Sentinel-2 bands of a product share a CRS, use first layer for reprojection
+	first := r.layers[0].dataset
+	var err error
+	r.projection, err = newProjection(first.ProjectionRef())
+	if err != nil {
+		r.Close()
+		return nil, err
+	}
+	west, south, east, north := rasterBounds(r.projection, first.GeoTransform(), first.RasterXSize(), first.RasterYSize())
+	r.extent = [4]float64{west, south, east, north}
+	return r, nil
+}
+
+// Close closes all datasets opened by the renderer
+func (r *rasterRenderer) Close() {
+	closed := make(map[*gdal.Dataset]bool)
+	for _, layer := range r.layers {
+		if !closed[layer.dataset] {
+			layer.dataset.Close()
+			closed[layer.dataset] = true
+		}
+	}
+	if r.projection != nil {
+		r.projection.Close()
+	}
+}
+
+func (r *rasterRenderer) bounds() (west, south, east, north float64) {
+	return r.extent[0], r.extent[1], r.extent[2], r.extent[3]
+}
+
+func (r *rasterRenderer) renderTile(z, x, y int) (*image.RGBA, error) {
+	values, err := r.sampleTile(z, x, y)
+	if err != nil || values == nil {
+		return nil, err
+	}
+
+	// Colorize samples, leaving pixels outside of the raster transparent
+	img := image.NewRGBA(image.Rect(0, 0, tileSize, tileSize))
+	channels := len(r.layers)
+	empty := true
+	for i := 0; i < tileSize*tileSize; i++ {
+		pixel := values[i*channels : (i+1)*channels]
+		if pixel[0] == math.MaxUint16 {
+			continue
+		}
+		c := r.colorize(pixel)
+		if c.A != 0 {
+			empty = false
+		}
+		img.SetRGBA(i%tileSize, i/tileSize, c)
+	}
+	if empty {
+		return nil, nil
+	}
+	return img, nil
+}
+
+// sampleTile returns the nearest neighbour value of each layer for every tile pixel, interleaved by layer.
+// Pixels outside of the raster are set to math.MaxUint16. Returns nil if the tile does not overlap the raster.
+func (r *rasterRenderer) sampleTile(z, x, y int) ([]uint16, error) {
+	// Get tile pixel centers in raster CRS
+	px, py := tilePoints(z, x, y)
+	r.projection.forward(px, py)
+
+	channels := len(r.layers)
+	values := make([]uint16, tileSize*tileSize*channels)
+	for i := range values {
+		values[i] = math.MaxUint16
+	}
+
+	overlaps := false
+	cols := make([]float64, len(px))
+	rows := make([]float64, len(py))
+	for l, layer := range r.layers {
+		// Get pixel/line of every tile pixel
+		for i := range px {
+			cols[i], rows[i] = applyGeoTransform(layer.inverse, px[i], py[i])
+		}
+
+		// Read smallest window containing the tile
+		buffer, window, err := readWindow(layer, cols, rows)
+		if err != nil {
+			return nil, err
+		}
+		if buffer == nil {
+			continue
+		}
+		overlaps = true
+
+		sampleWindow(values, channels, l, cols, rows, buffer, window, layer.width, layer.height)
+	}
+	if !overlaps {
+		return nil, nil
+	}
+	return values, nil
+}
+
+// sampleWindow sets channel l of values to the nearest neighbour of every coordinate in the window read into buffer.
+// Coordinates outside of the raster or not transformable (NaN or infinite) are skipped.
+func sampleWindow(values []uint16, channels, l int, cols, rows []float64, buffer []uint16, window rasterWindow, width, height int) {
+	for i := range cols {
+		col, row := cols[i], rows[i]
+		if !finite(col) || !finite(row) || col < 0 || row < 0 || col >= float64(width) || row >= float64(height) {
+			continue
+		}
+		bx := int((col - float64(window.x)) * float64(window.bufx) / float64(window.width))
+		by := int((row - float64(window.y)) * float64(window.bufy) / float64(window.height))
+		if bx < 0 || by < 0 {
+			continue
+		}
+		if bx >= window.bufx {
+			bx = window.bufx - 1
+		}
+		if by >= window.bufy {
+			by = window.bufy - 1
+		}
+		values[i*channels+l] = buffer[by*window.bufx+bx]
+	}
+}
+
+// finite reports whether v is neither NaN nor infinite
+func finite(v float64) bool {
+	return !math.IsNaN(v) && !math.IsInf(v, 0)
+}
+
+// rasterWindow describes a window read from a raster into a buffer of size bufx*bufy
+type rasterWindow struct {
+	x, y, width, height int
+	bufx, bufy          int
+}
+
+// readWindow reads the part of layer covered by the pixel coordinates cols/rows.
+// The window is decimated by GDAL to roughly the resolution of the coordinates.
+func readWindow(layer rasterLayer, cols, rows []float64) ([]uint16, rasterWindow, error) {
+	w, ok := tileWindow(cols, rows, layer.width, layer.height)
+	if !ok {
+		return nil, w, nil
+	}
+
+	buffer := make([]uint16, w.bufx*w.bufy)
+	err := layer.dataset.IO(
+		gdal.Read,
+		w.x,
+		w.y,
+		w.width,
+		w.height,
+		buffer,
+		w.bufx,
+		w.bufy,
+		1,
+		[]int{layer.band},
+		0,
+		0,
+		0)
+	if err != nil {
+		return nil, w, errors.New("Error reading data from Dataset: " + err.Error())
+	}
+	return buffer, w, nil
+}
+
+// tileWindow returns the window of a width*height raster covered by the pixel coordinates cols/rows and
+// the buffer size decimating it to roughly the resolution of the coordinates. Non-finite coordinates are ignored.
+// Returns false if the coordinates do not overlap the raster.
+func tileWindow(cols, rows []float64, width, height int) (rasterWindow, bool) {
+	var w rasterWindow
+	minx, miny := math.Inf(1), math.Inf(1)
+	maxx, maxy := math.Inf(-1), math.Inf(-1)
+	step := math.NaN()
+	for i := range cols {
+		if !finite(cols[i]) || !finite(rows[i]) {
+			continue
+		}
+		minx = math.Min(minx, cols[i])
+		maxx = math.Max(maxx, cols[i])
+		miny = math.Min(miny, rows[i])
+		maxy = math.Max(maxy, rows[i])
+
+		// Source pixels per tile pixel from the first pair of horizontal neighbours
+		if math.IsNaN(step) && i%tileSize != 0 && finite(cols[i-1]) && finite(rows[i-1]) {
+			step = math.Hypot(cols[i]-cols[i-1], rows[i]-rows[i-1])
+		}
+	}
+	if math.IsInf(minx, 0) {
+		return w, false
+	}
+
+	// Clip to raster
+	x0 := int(math.Max(math.Floor(minx), 0))
+	y0 := int(math.Max(math.Floor(miny), 0))
+	x1 := int(math.Min(math.Ceil(maxx)+1, float64(width)))
+	y1 := int(math.Min(math.Ceil(maxy)+1, float64(height)))
+	if x1 <= x0 || y1 <= y0 {
+		return w, false
+	}
+	w.x, w.y, w.width, w.height = x0, y0, x1-x0, y1-y0
+
+	if math.IsNaN(step) || step < 1 {
+		step = 1
+	}
+	w.bufx = int(math.Ceil(float64(w.width) / step))
+	w.bufy = int(math.Ceil(float64(w.height) / step))
+	return w, true
+}
+
+// byteColors returns a colorizer for 8-bit grey, RGB or RGBA datasets where 0 denotes nodata
+func byteColors(values []uint16) color.RGBA {
+	switch len(values) {
+	case 1:
+		if values[0] == 0 {
+			return color.RGBA{}
+		}
+		v := uint8(values[0])
+		return color.RGBA{v, v, v, 255}
+	case 4:
+		return color.RGBA{uint8(values[0]), uint8(values[1]), uint8(values[2]), uint8(values[3])}
+	default:
+		if values[0] == 0 && values[1] == 0 && values[2] == 0 {
+			return color.RGBA{}
+		}
+		return color.RGBA{uint8(values[0]), uint8(values[1]), uint8(values[2]), 255}
+	}
+}
+
+// tileDataset writes a TMS pyramid of all bands of an 8-bit dataset into directory
+func tileDataset(filename, directory string, progress func(percent int)) error {
+	dataset, err := gdal.Open(filename, gdal.ReadOnly)
+	if err != nil {
+		return errors.New("Error opening Dataset: " + err.Error())
+	}
+	bandcount := dataset.RasterCount()
+	dataset.Close()
+
+	files := make([]string, bandcount)
+	bands := make([]int, bandcount)
+	for i := range bands {
+		files[i] = filename
+		bands[i] = i + 1
+	}
+	renderer, err := newRasterRenderer(files, bands, byteColors)
+	if err != nil {
+		return err
+	}
+	defer renderer.Close()
+	return writePyramid(renderer, directory, MinZoom, MaxZoom, progress)
+}
+
+// writePyramid renders all tiles between minzoom and maxzoom to directory/{z}/{x}/{y}.png
+func writePyramid(renderer tileRenderer, directory string, minzoom, maxzoom int, progress func(percent int)) error {
+	defer Timetrack(time.Now(), "Tiling "+directory)
+	west, south, east, north := renderer.bounds()
+	if math.IsInf(west, 0) || math.IsInf(north, 0) {
+		return errors.New("Unable to compute extent of Dataset")
+	}
+
+	// Count tiles to report progress
+	total := 0
+	for z := minzoom; z <= maxzoom; z++ {
+		minx, miny, maxx, maxy := tileRange(z, west, south, east, north)
+		total += (maxx - minx + 1) * (maxy - miny + 1)
+	}
+
+	done := 0
+	for z := minzoom; z <= maxzoom; z++ {
+		minx, miny, maxx, maxy := tileRange(z, west, south, east, north)
+		for x := minx; x <= maxx; x++ {
+			for y := miny; y <= maxy; y++ {
+				img, err := renderer.renderTile(z, x, y)
+				if err != nil {
+					return err
+				}
+				if img != nil {
+					err = writeTile(img, directory, z, x, y)
+					if err != nil {
+						return err
+					}
+				}
+			}
+			done += maxy - miny + 1
+		}
+		if Verbose {
+			fmt.Println("Finished zoom level " + strconv.Itoa(z) + " of " + directory)
+		}
+		if progress != nil {
+			progress(done * 100 / total)
+		}
+	}
+	return nil
+}
+
+// writeTile encodes img as PNG to directory/{z}/{x}/{y}.png
+func writeTile(img image.Image, directory string, z, x, y int) error {
+	folder := directory + "/" + strconv.Itoa(z) + "/" + strconv.Itoa(x)
+	err := os.MkdirAll(folder, 0755)
+	if err != nil {
+		return err
+	}
+	file, err := os.Create(folder + "/" + strconv.Itoa(y) + ".png")
+	if err != nil {
+		return err
+	}
+	err = png.Encode(file, img)
+	if err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+// Geotransform of a 10m Sentinel-2 raster in UTM
+var utmGeoTransform = [6]float64{600000, 10, 0, 5700000, 0, -10}
+
+// tileCoordinates returns pixel/line coordinates of points in raster CRS like sampleTile computes them
+func tileCoordinates(t *testing.T, x, y []float64) (cols, rows []float64) {
+	inverse, err := invertGeoTransform(utmGeoTransform)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cols = make([]float64, len(x))
+	rows = make([]float64, len(y))
+	for i := range x {
+		cols[i], rows[i] = applyGeoTransform(inverse, x[i], y[i])
+	}
+	return cols, rows
+}
+
+func TestTileWindow(t *testing.T) {
+	inf := math.Inf(1)
+	tests := []struct {
+		name   string
+		x, y   []float64
+		ok     bool
+		window rasterWindow
+	}{
+		{"inside", []float64{600105, 600205}, []float64{5699895, 5699795}, true, rasterWindow{10, 10, 12, 12, 1, 1}},
+		{"clipped", []float64{599000, 600055}, []float64{5701000, 5699945}, true, rasterWindow{0, 0, 7, 7, 1, 1}},
+		{"outside", []float64{500000, 500010}, []float64{5699895, 5699895}, false, rasterWindow{}},
+		// Points outside of the valid range of the projection are marked +Inf and become NaN in pixel space
+		{"not transformable", []float64{inf, inf}, []float64{inf, inf}, false, rasterWindow{}},
+		{"partly transformable", []float64{inf, 600105, 600115}, []float64{inf, 5699895, 5699895}, true, rasterWindow{10, 10, 3, 2, 3, 2}},
+	}
+	for _, test := range tests {
+		cols, rows := tileCoordinates(t, test.x, test.y)
+		window, ok := tileWindow(cols, rows, 10980, 10980)
+		if ok != test.ok {
+			t.Errorf("%s: ok = %v, want %v", test.name, ok, test.ok)
+			continue
+		}
+		if ok && window != test.window {
+			t.Errorf("%s: window = %+v, want %+v", test.name, window, test.window)
+		}
+	}
+}
+
+// Regression test for tiles outside of the valid range of a UTM projection, which used to index the buffer with negative offsets
+func TestSampleWindowSkipsNonFiniteCoordinates(t *testing.T) {
+	inf := math.Inf(1)
+	x := []float64{inf, 600105, inf, 600115, math.NaN()}
+	y := []float64{inf, 5699895, 5699895, 5699895, 5699895}
+	cols, rows := tileCoordinates(t, x, y)
+	window, ok := tileWindow(cols, rows, 10980, 10980)
+	if !ok {
+		t.Fatal("tileWindow did not find the transformable points")
+	}
+	buffer := make([]uint16, window.bufx*window.bufy)
+	for i := range buffer {
+		buffer[i] = uint16(i + 1)
+	}
+
+	values := []uint16{math.MaxUint16, math.MaxUint16, math.MaxUint16, math.MaxUint16, math.MaxUint16}
+	sampleWindow(values, 1, 0, cols, rows, buffer, window, 10980, 10980)
+	want := []uint16{math.MaxUint16, 1, math.MaxUint16, 2, math.MaxUint16}
+	for i := range want {
+		if values[i] != want[i] {
+			t.Errorf("values[%d] = %d, want %d", i, values[i], want[i])
+		}
+	}
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"fmt"
+	"github.com/paulsmith/gogeos/geos"
+	"math"
+	"net/http"
+	"sort"
+	"time"
+)
+
+// Scene classes flagging an observation as cloudy
+var cloudySceneClasses = []string{"shadow", "cloud_medium", "cloud_high", "cirrus"}
+
+// Response Schema of /timeseries
+type timeseriesResponse struct {
+	Lon          float64                 `json:"lon"`
+	Lat          float64                 `json:"lat"`
+	Band         string                  `json:"band"`
+	Observations []timeseriesObservation `json:"observations"`
+}
+
+type timeseriesObservation struct {
+	Date       time.Time `json:"date"`
+	Dataset    string    `json:"dataset"`
+	Level      string    `json:"level"`
+	CloudCover *float64  `json:"cloudCover"`
+	// Reflectance or index value, nil for nodata
+	Value *float64 `json:"value"`
+	// Scene classification, only available for L2A products
+	SceneClass     *int   `json:"sceneClass,omitempty"`
+	SceneClassName string `json:"sceneClassName,omitempty"`
+	Cloudy         bool   `json:"cloudy"`
+}
+
+// TimeseriesHandler returns the value of a band or index at a point for every product containing the point
+func TimeseriesHandler(w http.ResponseWriter, r *http.Request) {
+	defer Timetrack(time.Now(), "Timeseries ")
+	q := r.URL.Query()
+
+	// Log request if verbose is set
+	if Verbose {
+		fmt.Print("Request to /timeseries with parameters: ")
+		fmt.Println(q)
+	}
+
+	lon, lat, err := parseLonLat(q.Get("x"), q.Get("y"))
+	if err != nil {
+		w.WriteHeader(400)
+		w.Write([]byte(err.Error()))
+		return
+	}
+	if q.Get("b") == "" {
+		w.WriteHeader(400)
+		w.Write([]byte("Parameter 'b' is required"))
+		return
+	}
+	// Single bands are expressions as well and evaluate to their reflectance
+	expr, err := parseExpression(q.Get("b"))
+	if err != nil {
+		w.WriteHeader(400)
+		w.Write([]byte("Invalid band or expression: " + err.Error()))
+		return
+	}
+	from, to, err := parseDayRange(q.Get("from"), q.Get("to"))
+	if err != nil {
+		w.WriteHeader(400)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	// Get all Datasets containing the point
+	datasets, err := Index.All()
+	if err != nil {
+		w.WriteHeader(500)
+		w.Write([]byte("Unable to read Index: " + err.Error()))
+		return
+	}
+	err = propertiesFilter(datasets, q)
+	if err != nil {
+		w.WriteHeader(400)
+		w.Write([]byte(err.Error()))
+		return
+	}
+	point, err := geos.NewPoint(geos.Coord{X: lon, Y: lat})
+	if err != nil {
+		w.WriteHeader(500)
+		w.Write([]byte(err.Error()))
+		return
+	}
+	spatial, err := newSpatialFilter(point, "contains", "")
+	if err != nil {
+		w.WriteHeader(500)
+		w.Write([]byte(err.Error()))
+		return
+	}
+	// Observations are dated by sensing time, products may have been generated much later
+	sensingTimeFilter(datasets, from, to)
+	err = metaDataFilter(datasets, "", "", spatial, false)
+	if err != nil {
+		w.WriteHeader(500)
+		w.Write([]byte("Unable to filter by metadata: " + err.Error()))
+		return
+	}
+
+	// Sort by date
+	var products []*Product
+	for _, dataset := range datasets {
+		if dataset != nil {
+			products = append(products, dataset)
+		}
+	}
+	sort.Slice(products, func(i, j int) bool { return products[i].SensingTime.Before(products[j].SensingTime) })
+
+	response := timeseriesResponse{
+		Lon:          lon,
+		Lat:          lat,
+		Band:         q.Get("b"),
+		Observations: []timeseriesObservation{},
+	}
+	for _, p := range products {
+		observation, err := observe(p, expr, lon, lat)
+		if err != nil {
+			// Skip products missing bands or not covering the point despite their footprint
+			if Verbose {
+				fmt.Println("Skipping " + p.Name + ": " + err.Error())
+			}
+			continue
+		}
+		response.Observations = append(response.Observations, observation)
+	}
+	writeJSON(w, "application/json", response)
+}
+
+// sensingTimeFilter sets all Elements in datasets to nil whose sensing time is not within from and to
+func sensingTimeFilter(datasets []*Product, from, to time.Time) {
+	for i, dataset := range datasets {
+		if dataset != nil && (dataset.SensingTime.Before(from) || dataset.SensingTime.After(to)) {
+			datasets[i] = nil
+		}
+	}
+}
+
+// observe evaluates expr and reads the scene class of product p at lon/lat
+func observe(p *Product, expr *expression, lon, lat float64) (timeseriesObservation, error) {
+	observation := timeseriesObservation{
+		Date:       p.SensingTime,
+		Dataset:    p.Name,
+		Level:      p.Level,
+		CloudCover: p.CloudCover,
+	}
+
+	// Read all referenced bands
+	dn := make([]uint16, len(expr.bands))
+	for i, band := range expr.bands {
+		location, err := bandLocation(p.Name, band)
+		if err != nil {
+			return observation, err
+		}
+		values, _, _, err := readPixel(location, lon, lat)
+		if err != nil {
+			return observation, err
+		}
+		dn[i] = values[0]
+	}
+	value := expr.evalDN(dn, make([]float64, len(dn)))
+	if !math.IsNaN(value) {
+		observation.Value = &value
+	}
+
+	// Flag clouds using the scene classification of L2A products
+	location, err := bandLocation(p.Name, "SCL")
+	if err == ErrBandNotFound {
+		return observation, nil
+	}
+	if err != nil {
+		return observation, err
+	}
+	values, _, _, err := readPixel(location, lon, lat)
+	if err != nil {
+		return observation, err
+	}
+	class := int(values[0])
+	observation.SceneClass = &class
+	observation.SceneClassName = sceneClassName(class)
+	for _, name := range cloudySceneClasses {
+		observation.Cloudy = observation.Cloudy || SceneClasses[name] == class
+	}
+	return observation, nil
+}
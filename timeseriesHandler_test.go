@@ -0,0 +1,34 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSensingTimeFilter(t *testing.T) {
+	day := func(month time.Month, d int) time.Time {
+		return time.Date(2018, month, d, 10, 30, 0, 0, time.UTC)
+	}
+	datasets := []*Product{
+		// Reprocessed in May but sensed in April
+		{Name: "april", SensingTime: day(4, 20), GenerationTime: day(5, 10)},
+		// Sensed in May but reprocessed in June
+		{Name: "may", SensingTime: day(5, 15), GenerationTime: day(6, 2)},
+		nil,
+		{Name: "june", SensingTime: day(6, 1), GenerationTime: day(6, 1)},
+	}
+	from, to, err := parseDayRange("2018-05-01", "2018-05-31")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sensingTimeFilter(datasets, from, to)
+	var names []string
+	for _, dataset := range datasets {
+		if dataset != nil {
+			names = append(names, dataset.Name)
+		}
+	}
+	if len(names) != 1 || names[0] != "may" {
+		t.Errorf("sensingTimeFilter kept %v, want [may]", names)
+	}
+}
@@ -1,22 +1,39 @@
 package main
 
 import (
-	"encoding/xml"
+	"errors"
 	"fmt"
-	"io/ioutil"
+	"github.com/ling-js/go-gdal"
+	"math"
 	"net/http"
-	"os/exec"
+	"strconv"
+	"strings"
 	"time"
 )
 
-// Response Schemata of gdallocation script
-type report struct {
-	Bands []bandreport `xml:"BandReport"`
+// ErrOutsideDataset is returned when a coordinate is not covered by a raster
+var ErrOutsideDataset = errors.New("Coordinate is outside of Dataset")
+
+// Names of the channels of true color images
+var tciChannels = []string{"red", "green", "blue"}
+
+// Response Schema of /value
+type valueResponse struct {
+	Dataset string        `json:"dataset"`
+	File    string        `json:"file"`
+	Lon     float64       `json:"lon"`
+	Lat     float64       `json:"lat"`
+	Pixel   int           `json:"pixel"`
+	Line    int           `json:"line"`
+	Values  []valueResult `json:"values"`
 }
 
-type bandreport struct {
-	File  string `xml:"LocationInfo>File"`
-	Value string `xml:"Value"`
+type valueResult struct {
+	Band string `json:"band"`
+	DN   uint16 `json:"dn"`
+	// Top or bottom of atmosphere reflectance, only set for spectral bands
+	Reflectance *float64 `json:"reflectance,omitempty"`
+	Nodata      bool     `json:"nodata,omitempty"`
 }
 
 // LookupHandler handles all Requests for concrete Dataset values
@@ -24,8 +41,6 @@ func LookupHandler(w http.ResponseWriter, r *http.Request) {
 	defer Timetrack(time.Now(), "ValueLookup")
 	// Get Query Parameters
 	q := r.URL.Query()
-	xcoord := q.Get("x")
-	ycoord := q.Get("y")
 	datasetname := q.Get("d")
 	bandname := q.Get("b")
 
@@ -35,86 +50,134 @@ func LookupHandler(w http.ResponseWriter, r *http.Request) {
 		fmt.Println(q)
 	}
 
-	// local variables
-	var output []byte
-	var err error
-	tci := false
+	if datasetname == "" || bandname == "" {
+		w.WriteHeader(400)
+		w.Write([]byte("Parameters 'd' and 'b' are required"))
+		return
+	}
+	lon, lat, err := parseLonLat(q.Get("x"), q.Get("y"))
+	if err != nil {
+		w.WriteHeader(400)
+		w.Write([]byte(err.Error()))
+		return
+	}
 
-	// Check if S2A Dataset
-	if datasetname[:8] != "SENTINEL" {
+	// Get location of band inside dynamically named subfolder
+	location, err := bandLocation(datasetname, bandname)
+	if err != nil {
+		w.WriteHeader(404)
+		w.Write([]byte("Cannot find Band in Dataset: " + err.Error()))
+		return
+	}
 
-		// check if TCI Dataset
-		if bandname[len(bandname)-11:len(bandname)-8] == "TCI" {
-			tci = true
-		}
+	// Get Pixel Data
+	values, pixel, line, err := readPixel(location, lon, lat)
+	if err == ErrOutsideDataset {
+		w.WriteHeader(400)
+		w.Write([]byte(err.Error()))
+		return
+	}
+	if err != nil {
+		w.WriteHeader(500)
+		w.Write([]byte("Error reading value: " + err.Error()))
+		return
+	}
 
-		// Get Name of dynamically named subfolder
-		datalocation := DataSource + datasetname + "/GRANULE/"
-		subfolder, err := ioutil.ReadDir(datalocation)
-		if err != nil {
-			w.WriteHeader(404)
-			w.Write([]byte("Cannot find Dataset"))
-			return
+	response := valueResponse{
+		Dataset: productName(datasetname),
+		File:    location[strings.LastIndex(location, "/")+1:],
+		Lon:     lon,
+		Lat:     lat,
+		Pixel:   pixel,
+		Line:    line,
+	}
+	band := bandOfFile(location)
+	for i, dn := range values {
+		result := valueResult{Band: band, DN: dn, Nodata: dn == 0}
+		if len(values) == len(tciChannels) && band == "TCI" {
+			result.Band = tciChannels[i]
+		} else if bandPattern.MatchString(band) && dn != 0 {
+			reflectance := float64(dn) / quantificationValue
+			result.Reflectance = &reflectance
 		}
+		response.Values = append(response.Values, result)
+	}
+	writeJSON(w, "application/json", response)
+}
 
-		// Get Resolution
-		resolution := bandname[len(bandname)-7 : len(bandname)-5]
+// parseLonLat parses WGS84 coordinates given as strings
+func parseLonLat(x, y string) (lon, lat float64, err error) {
+	lon, err = strconv.ParseFloat(x, 64)
+	if err != nil || lon < -180 || lon > 180 {
+		return 0, 0, errors.New("Parameter 'x' must be a longitude between -180 and 180")
+	}
+	lat, err = strconv.ParseFloat(y, 64)
+	if err != nil || lat < -90 || lat > 90 {
+		return 0, 0, errors.New("Parameter 'y' must be a latitude between -90 and 90")
+	}
+	return lon, lat, nil
+}
 
-		// Get Pixel Data
-		datasetlocation := DataSource + datasetname + "/GRANULE/" + subfolder[0].Name() + "/IMG_DATA/R" + resolution + "m/" + bandname
-		output, err = exec.Command("gdallocationinfo", "-xml", "-wgs84", datasetlocation, xcoord, ycoord).Output()
-		if err != nil {
-			fmt.Println(err.Error())
-		}
-	} else {
-		// check if TCI Dataset
-		if bandname == "TCI" {
-			tci = true
-		}
-		// Get Pixel Data
-		output, err = exec.Command("gdallocationinfo", "-xml", "-wgs84", datasetname, xcoord, ycoord).Output()
+// bandOfFile returns the band name of a jp2 file like B04 or TCI, ignoring resolutions
+func bandOfFile(location string) string {
+	band := stacAssetKey(location)
+	if i := strings.LastIndex(band, "_"); i != -1 && strings.HasSuffix(band, "m") {
+		band = band[:i]
 	}
-	// Check for error while executing gdallocationinfo
+	return band
+}
+
+// readPixel reads all bands of the raster at location at the pixel covering lon/lat
+func readPixel(location string, lon, lat float64) (values []uint16, pixel, line int, err error) {
+	dataset, err := gdal.Open(location, gdal.ReadOnly)
 	if err != nil {
-		w.WriteHeader(500)
-		w.Write([]byte("Error executing Value Lookup. Error was: " + err.Error()))
+		return nil, 0, 0, errors.New("Error opening Dataset: " + err.Error())
 	}
+	defer dataset.Close()
 
-	// Parse Output from xml to Go struct
-	var v report
-	err = xml.Unmarshal(output, &v)
+	// Transform coordinate into raster CRS and pixel/line
+	p, err := newProjection(dataset.ProjectionRef())
 	if err != nil {
-		w.WriteHeader(500)
-		w.Write([]byte("Error parsing xml. Error was: " + err.Error()))
+		return nil, 0, 0, err
 	}
-
-	// Compute TCI Values into json array
-	if tci {
-		w.Write([]byte("[" + v.Bands[0].Value + "," + v.Bands[1].Value + "," + v.Bands[2].Value + "]"))
-		return
+	defer p.Close()
+	x, y := []float64{lon}, []float64{lat}
+	p.forward(x, y)
+	if math.IsInf(x[0], 0) {
+		return nil, 0, 0, ErrOutsideDataset
 	}
-
-	// Check if S2A Dataset
-	if datasetname[:8] == "SENTINEL" {
-		// Get and Return Value corresponding to provided bandname
-		for index := range v.Bands {
-			fmt.Println("")
-			// Extract Bandname from Filename
-			band := v.Bands[index].File
-			band = band[len(band)-7 : len(band)-4]
-			if bandname == band {
-				w.Write([]byte(v.Bands[index].Value))
-				return
-			}
-		}
-	} else {
-		if v.Bands != nil {
-			w.Write([]byte(v.Bands[0].Value))
-			return
-		}
+	inverse, err := invertGeoTransform(dataset.GeoTransform())
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	col, row := applyGeoTransform(inverse, x[0], y[0])
+	pixel, line = int(math.Floor(col)), int(math.Floor(row))
+	if col < 0 || row < 0 || pixel >= dataset.RasterXSize() || line >= dataset.RasterYSize() {
+		return nil, 0, 0, ErrOutsideDataset
 	}
 
-	// If Band is not found in Dataset
-	w.WriteHeader(404)
-	w.Write(append([]byte("Error while finding Band in Dataset \n Debug output below: \n \n "), output...))
+	// Read single pixel of all bands
+	bands := make([]int, dataset.RasterCount())
+	for i := range bands {
+		bands[i] = i + 1
+	}
+	values = make([]uint16, len(bands))
+	err = dataset.IO(
+		gdal.Read,
+		pixel,
+		line,
+		1,
+		1,
+		values,
+		1,
+		1,
+		len(bands),
+		bands,
+		0,
+		0,
+		0)
+	if err != nil {
+		return nil, 0, 0, errors.New("Error reading data from Dataset: " + err.Error())
+	}
+	return values, pixel, line, nil
 }
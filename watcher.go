@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+	"github.com/fsnotify/fsnotify"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// WatchDataSource watches DataSource for new, changed and removed products and updates the index accordingly.
+// Products are only read once no events occurred for settle, so products still being copied are not rejected early.
+func WatchDataSource(settle time.Duration) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+
+	// Watch DataSource for added and removed products and the root of every product for changed files.
+	// Subfolders are not watched to stay below the inotify watch limit, changes deep inside products are picked up by rescans.
+	err = watcher.Add(DataSource)
+	if err != nil {
+		watcher.Close()
+		return err
+	}
+	entries, err := ioutil.ReadDir(DataSource)
+	if err != nil {
+		watcher.Close()
+		return err
+	}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			watchProduct(watcher, DataSource+entry.Name())
+		}
+	}
+
+	go func() {
+		defer watcher.Close()
+		var mutex sync.Mutex
+		pending := make(map[string]*time.Timer)
+
+		for {
+			select {
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				name := watchedProduct(event.Name)
+				if name == "" {
+					continue
+				}
+				if Verbose {
+					fmt.Println("Watcher: " + event.String())
+				}
+
+				// Watch newly created products
+				if event.Op&fsnotify.Create == fsnotify.Create && filepath.Dir(filepath.Clean(event.Name)) == filepath.Clean(DataSource) {
+					info, err := os.Stat(event.Name)
+					if err == nil && info.IsDir() {
+						watchProduct(watcher, event.Name)
+					}
+				}
+
+				// Restart timer of product on every event
+				mutex.Lock()
+				if timer, ok := pending[name]; ok {
+					timer.Stop()
+				}
+				pending[name] = time.AfterFunc(settle, func() {
+					mutex.Lock()
+					delete(pending, name)
+					mutex.Unlock()
+					err := Index.Update(name)
+					if err != nil {
+						fmt.Println("Unable to update index: " + err.Error())
+					}
+				})
+				mutex.Unlock()
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				fmt.Println("Watcher error: " + err.Error())
+			}
+		}
+	}()
+	return nil
+}
+
+// watchProduct adds the root folder of a product to watcher, any event inside it updates the whole product
+func watchProduct(watcher *fsnotify.Watcher, location string) {
+	err := watcher.Add(location)
+	if err != nil {
+		fmt.Println("Unable to watch " + location + ": " + err.Error())
+	}
+}
+
+// watchedProduct returns the name of the product a file in DataSource belongs to
+func watchedProduct(location string) string {
+	relative, err := filepath.Rel(DataSource, location)
+	if err != nil || relative == "." || strings.HasPrefix(relative, "..") {
+		return ""
+	}
+	return strings.Split(relative, string(filepath.Separator))[0]
+}
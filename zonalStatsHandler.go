@@ -0,0 +1,403 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/ling-js/go-gdal"
+	"github.com/paulsmith/gogeos/geos"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// Request Schema of /zonalstats
+type zonalStatsRequest struct {
+	Dataset string `json:"dataset"`
+	// Band name, band math expression or name of a built-in index
+	Band string `json:"band"`
+	// Comma separated scene classes to mask
+	Mask string `json:"mask"`
+	// GeoJSON Polygon, MultiPolygon, Feature or FeatureCollection in WGS84
+	Geometry json.RawMessage `json:"geometry"`
+}
+
+// Response Schema of /zonalstats
+type zonalStatsResponse struct {
+	Dataset  string              `json:"dataset"`
+	Band     string              `json:"band"`
+	Features []zonalStatsFeature `json:"features"`
+}
+
+type zonalStatsFeature struct {
+	ID         interface{}            `json:"id,omitempty"`
+	Properties map[string]interface{} `json:"properties,omitempty"`
+	zonalStatistics
+}
+
+// zonalStatistics summarizes the values of all pixels inside a zone
+type zonalStatistics struct {
+	// Number of pixels inside the zone, split into valid, masked and nodata pixels
+	Pixels int `json:"pixels"`
+	Valid  int `json:"valid"`
+	Masked int `json:"masked"`
+	Nodata int `json:"nodata"`
+	// Statistics of valid pixels, nil if there are none
+	Min    *float64 `json:"min"`
+	Max    *float64 `json:"max"`
+	Mean   *float64 `json:"mean"`
+	Median *float64 `json:"median"`
+	StdDev *float64 `json:"stddev"`
+}
+
+// ZonalStatsHandler returns statistics of a band or index of a Dataset inside one or more polygons
+func ZonalStatsHandler(w http.ResponseWriter, r *http.Request) {
+	defer Timetrack(time.Now(), "Zonal Statistics ")
+
+	var request zonalStatsRequest
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		w.WriteHeader(400)
+		w.Write([]byte("Unable to parse request body: " + err.Error()))
+		return
+	}
+
+	// Log request if verbose is set
+	if Verbose {
+		fmt.Println("Request to /zonalstats for " + request.Dataset + " and band " + request.Band)
+	}
+
+	if request.Dataset == "" || request.Band == "" || request.Geometry == nil {
+		w.WriteHeader(400)
+		w.Write([]byte("Fields 'dataset', 'band' and 'geometry' are required"))
+		return
+	}
+	expr, err := parseExpression(request.Band)
+	if err != nil {
+		w.WriteHeader(400)
+		w.Write([]byte("Invalid band or expression: " + err.Error()))
+		return
+	}
+	if request.Mask != "" {
+		_, err = parseMaskClasses(request.Mask)
+		if err != nil {
+			w.WriteHeader(400)
+			w.Write([]byte(err.Error()))
+			return
+		}
+	}
+	features, err := parseFeatures(request.Geometry)
+	if err != nil {
+		w.WriteHeader(400)
+		w.Write([]byte(err.Error()))
+		return
+	}
+	zones, err := featureGeometries(features)
+	if err != nil {
+		w.WriteHeader(400)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	// Check that all bands exist before reading them
+	err = checkBands(request.Dataset, expr)
+	if err != nil {
+		w.WriteHeader(404)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	data, masked, grid, err := readZoneData(request.Dataset, expr, request.Mask, zones)
+	if err != nil {
+		w.WriteHeader(500)
+		w.Write([]byte("Unable to read Dataset: " + err.Error()))
+		return
+	}
+	defer grid.Close()
+
+	response := zonalStatsResponse{
+		Dataset:  productName(request.Dataset),
+		Band:     request.Band,
+		Features: []zonalStatsFeature{},
+	}
+	for i, zone := range zones {
+		pixels, err := grid.polygonPixels(zone)
+		if err != nil {
+			w.WriteHeader(400)
+			w.Write([]byte("Unable to rasterize feature " + strconv.Itoa(i) + ": " + err.Error()))
+			return
+		}
+		response.Features = append(response.Features, zonalStatsFeature{
+			ID:              features[i].ID,
+			Properties:      features[i].Properties,
+			zonalStatistics: zonalStatisticsOf(data, masked, pixels),
+		})
+	}
+	writeJSON(w, "application/json", response)
+}
+
+// featureGeometries converts the geometries of all features
+func featureGeometries(features []geoJSONFeature) ([]*geos.Geometry, error) {
+	if len(features) == 0 {
+		return nil, errors.New("No features given")
+	}
+	var geometries []*geos.Geometry
+	for i, feature := range features {
+		if feature.Geometry == nil {
+			return nil, errors.New("Feature " + strconv.Itoa(i) + " has no geometry")
+		}
+		geometry, err := geometryFromGeoJSON(*feature.Geometry)
+		if err != nil {
+			return nil, errors.New("Invalid geometry of feature " + strconv.Itoa(i) + ": " + err.Error())
+		}
+		geometries = append(geometries, geometry)
+	}
+	return geometries, nil
+}
+
+// checkBands returns an error if product does not contain all bands referenced by expr
+func checkBands(product string, expr *expression) error {
+	for _, band := range expr.bands {
+		_, err := bandLocation(product, band)
+		if err != nil {
+			return errors.New("Cannot find Band " + band + " in Dataset: " + err.Error())
+		}
+	}
+	return nil
+}
+
+// readZoneData evaluates expr on the part of product covered by zones and loads the scene mask there if set.
+// Returns the values, the masked pixels (nil without mask) and the grid of the values cropped to the zones.
+func readZoneData(product string, expr *expression, mask string, zones []*geos.Geometry) ([]float32, []bool, *rasterGrid, error) {
+	// Values are computed on the grid of the band with the highest resolution
+	var reference string
+	maxsize := 0
+	for _, band := range expr.bands {
+		location, err := bandLocation(product, band)
+		if err != nil {
+			return nil, nil, nil, errors.New("Unable to find band " + band + ": " + err.Error())
+		}
+		dataset, err := gdal.Open(location, gdal.ReadOnly)
+		if err != nil {
+			return nil, nil, nil, errors.New("Error opening Dataset: " + err.Error())
+		}
+		if dataset.RasterXSize() > maxsize {
+			maxsize = dataset.RasterXSize()
+			reference = location
+		}
+		dataset.Close()
+	}
+	grid, err := openRasterGrid(reference)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	// Only read the pixels within the extent of all zones
+	var x, y []float64
+	for _, zone := range zones {
+		polygons, err := polygonsOf(zone)
+		if err != nil {
+			grid.Close()
+			return nil, nil, nil, err
+		}
+		for _, polygon := range polygons {
+			for _, position := range polygon[0] {
+				x = append(x, position[0])
+				y = append(y, position[1])
+			}
+		}
+	}
+	grid.toPixel(x, y)
+	window := newZoneWindow(x, y, grid.width, grid.height)
+	grid.crop(window)
+
+	// Read all referenced bands
+	data := make([][]uint16, len(expr.bands))
+	for i, band := range expr.bands {
+		location, _ := bandLocation(product, band)
+		data[i], err = readBandWindow(location, window)
+		if err != nil {
+			grid.Close()
+			return nil, nil, nil, err
+		}
+	}
+	values := make([]float32, grid.width*grid.height)
+	dn := make([]uint16, len(data))
+	buffer := make([]float64, len(data))
+	for p := range values {
+		for i := range data {
+			dn[i] = data[i][p]
+		}
+		values[p] = float32(expr.evalDN(dn, buffer))
+	}
+
+	var masked []bool
+	if mask != "" {
+		masked, err = readMaskWindow(product, mask, window)
+		if err != nil {
+			grid.Close()
+			return nil, nil, nil, errors.New("Unable to load mask: " + err.Error())
+		}
+	}
+	return values, masked, grid, nil
+}
+
+// zoneWindow is the part x0 <= x < x1, y0 <= y < y1 of a width*height raster
+type zoneWindow struct {
+	x0, y0, x1, y1 int
+	width, height  int
+}
+
+// newZoneWindow returns the smallest window of a width*height raster covering all finite pixel coordinates x/y
+func newZoneWindow(x, y []float64, width, height int) zoneWindow {
+	minx, miny := math.Inf(1), math.Inf(1)
+	maxx, maxy := math.Inf(-1), math.Inf(-1)
+	for i := range x {
+		if !finite(x[i]) || !finite(y[i]) {
+			continue
+		}
+		minx, miny = math.Min(minx, x[i]), math.Min(miny, y[i])
+		maxx, maxy = math.Max(maxx, x[i]), math.Max(maxy, y[i])
+	}
+	w := zoneWindow{width: width, height: height}
+	if minx > maxx {
+		return w
+	}
+	clamp := func(v float64, size int) int {
+		return int(math.Max(0, math.Min(float64(size), v)))
+	}
+	w.x0, w.x1 = clamp(math.Floor(minx), width), clamp(math.Ceil(maxx), width)
+	w.y0, w.y1 = clamp(math.Floor(miny), height), clamp(math.Ceil(maxy), height)
+	if w.x0 >= w.x1 || w.y0 >= w.y1 {
+		w.x0, w.y0, w.x1, w.y1 = 0, 0, 0, 0
+	}
+	return w
+}
+
+// size returns the number of columns and rows of the window
+func (w zoneWindow) size() (cols, rows int) {
+	return w.x1 - w.x0, w.y1 - w.y0
+}
+
+// scale returns the window covering the same pixels in a raster of the same extent with width*height pixels
+func (w zoneWindow) scale(width, height int) zoneWindow {
+	s := zoneWindow{width: width, height: height}
+	if w.x0 >= w.x1 || w.y0 >= w.y1 {
+		return s
+	}
+	s.x0, s.y0 = w.x0*width/w.width, w.y0*height/w.height
+	s.x1, s.y1 = (w.x1-1)*width/w.width+1, (w.y1-1)*height/w.height+1
+	return s
+}
+
+// resample maps values of window src of another resolution to the pixels of w by nearest neighbour
+func (w zoneWindow) resample(values []uint16, src zoneWindow) []uint16 {
+	cols, rows := w.size()
+	srcCols, _ := src.size()
+	result := make([]uint16, cols*rows)
+	for row := 0; row < rows; row++ {
+		offset := ((w.y0+row)*src.height/w.height - src.y0) * srcCols
+		for col := 0; col < cols; col++ {
+			result[row*cols+col] = values[offset+(w.x0+col)*src.width/w.width-src.x0]
+		}
+	}
+	return result
+}
+
+// readBandWindow reads the first band of the raster at location inside window w of a raster with the same extent.
+// Rasters of another resolution are resampled to the pixels of w.
+func readBandWindow(location string, w zoneWindow) ([]uint16, error) {
+	dataset, err := gdal.Open(location, gdal.ReadOnly)
+	if err != nil {
+		return nil, errors.New("Error opening Dataset: " + err.Error())
+	}
+	defer dataset.Close()
+	src := w.scale(dataset.RasterXSize(), dataset.RasterYSize())
+	cols, rows := src.size()
+	values := make([]uint16, cols*rows)
+	if len(values) == 0 {
+		return values, nil
+	}
+	err = dataset.IO(gdal.Read, src.x0, src.y0, cols, rows, values, cols, rows, 1, []int{1}, 0, 0, 0)
+	if err != nil {
+		return nil, errors.New("Error reading data from Dataset: " + err.Error())
+	}
+	if src == w {
+		return values, nil
+	}
+	return w.resample(values, src), nil
+}
+
+// readMaskWindow returns which pixels of window w are covered by the scene classes in mask
+func readMaskWindow(product, mask string, w zoneWindow) ([]bool, error) {
+	classes, err := parseMaskClasses(mask)
+	if err != nil {
+		return nil, err
+	}
+
+	// Use scene classification of L2A products
+	location, err := bandLocation(product, "SCL")
+	if err == nil {
+		scl, err := readBandWindow(location, w)
+		if err != nil {
+			return nil, err
+		}
+		var selected [sceneClassCount]bool
+		for _, class := range classes {
+			selected[class] = true
+		}
+		masked := make([]bool, len(scl))
+		for i, class := range scl {
+			masked[i] = int(class) < sceneClassCount && selected[class]
+		}
+		return masked, nil
+	}
+	if err != ErrBandNotFound {
+		return nil, err
+	}
+
+	// Fall back to cloud polygons of L1C products
+	m, err := loadSceneMask(product, mask)
+	if err != nil {
+		return nil, err
+	}
+	return m.polygonWindow(w), nil
+}
+
+// zonalStatisticsOf computes statistics of data at the given pixels. NaN values count as nodata.
+func zonalStatisticsOf(data []float32, masked []bool, pixels []int) zonalStatistics {
+	s := zonalStatistics{Pixels: len(pixels)}
+	var values []float64
+	for _, pixel := range pixels {
+		switch {
+		case masked != nil && masked[pixel]:
+			s.Masked++
+		case math.IsNaN(float64(data[pixel])):
+			s.Nodata++
+		default:
+			values = append(values, float64(data[pixel]))
+		}
+	}
+	s.Valid = len(values)
+	if s.Valid == 0 {
+		return s
+	}
+
+	sort.Float64s(values)
+	var sum, sumsq float64
+	for _, v := range values {
+		sum += v
+		sumsq += v * v
+	}
+	min, max := values[0], values[len(values)-1]
+	mean := sum / float64(len(values))
+	stddev := math.Sqrt(math.Max(0, sumsq/float64(len(values))-mean*mean))
+	median := values[len(values)/2]
+	if len(values)%2 == 0 {
+		median = (values[len(values)/2-1] + values[len(values)/2]) / 2
+	}
+	s.Min, s.Max, s.Mean, s.Median, s.StdDev = &min, &max, &mean, &median, &stddev
+	return s
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestNewZoneWindow(t *testing.T) {
+	inf := math.Inf(1)
+	tests := []struct {
+		name string
+		x, y []float64
+		want zoneWindow
+	}{
+		{"inside", []float64{2.5, 7.2, 4}, []float64{3.5, 1.1, 8}, zoneWindow{2, 1, 8, 8, 10, 10}},
+		{"pixel edges", []float64{2, 4}, []float64{2, 4}, zoneWindow{2, 2, 4, 4, 10, 10}},
+		{"clamped", []float64{-5, 15}, []float64{5.5, 20}, zoneWindow{0, 5, 10, 10, 10, 10}},
+		{"outside", []float64{12, 15}, []float64{1, 5}, zoneWindow{0, 0, 0, 0, 10, 10}},
+		{"line", []float64{3, 3}, []float64{1, 5}, zoneWindow{0, 0, 0, 0, 10, 10}},
+		{"not transformable", []float64{inf, 1.5, 3.5}, []float64{inf, 1.5, 3.5}, zoneWindow{1, 1, 4, 4, 10, 10}},
+		{"empty", nil, nil, zoneWindow{0, 0, 0, 0, 10, 10}},
+	}
+	for _, test := range tests {
+		if got := newZoneWindow(test.x, test.y, 10, 10); got != test.want {
+			t.Errorf("%s: newZoneWindow = %+v, want %+v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestZoneWindowScale(t *testing.T) {
+	tests := []struct {
+		w             zoneWindow
+		width, height int
+		want          zoneWindow
+	}{
+		{zoneWindow{2, 4, 6, 8, 12, 12}, 12, 12, zoneWindow{2, 4, 6, 8, 12, 12}},
+		{zoneWindow{2, 4, 6, 8, 12, 12}, 6, 6, zoneWindow{1, 2, 3, 4, 6, 6}},
+		{zoneWindow{3, 5, 7, 6, 12, 12}, 6, 6, zoneWindow{1, 2, 4, 3, 6, 6}},
+		{zoneWindow{0, 0, 12, 12, 12, 12}, 2, 2, zoneWindow{0, 0, 2, 2, 2, 2}},
+		{zoneWindow{5, 5, 6, 6, 12, 12}, 2, 2, zoneWindow{0, 0, 1, 1, 2, 2}},
+		{zoneWindow{0, 0, 0, 0, 12, 12}, 6, 6, zoneWindow{0, 0, 0, 0, 6, 6}},
+	}
+	for _, test := range tests {
+		if got := test.w.scale(test.width, test.height); got != test.want {
+			t.Errorf("%+v.scale(%d, %d) = %+v, want %+v", test.w, test.width, test.height, got, test.want)
+		}
+	}
+}
+
+func TestZoneWindowResample(t *testing.T) {
+	// Pixels 1 to 3 of a 4 pixel wide raster read from a 2 pixel wide raster
+	w := zoneWindow{1, 1, 4, 3, 4, 4}
+	src := w.scale(2, 2)
+	values := []uint16{
+		1, 2,
+		3, 4,
+	}
+	want := []uint16{
+		1, 2, 2,
+		3, 4, 4,
+	}
+	if got := w.resample(values, src); !reflect.DeepEqual(got, want) {
+		t.Errorf("resample = %v, want %v", got, want)
+	}
+	if got := w.resample(want, w); !reflect.DeepEqual(got, want) {
+		t.Errorf("resample of the same window = %v, want %v", got, want)
+	}
+}
+
+func TestRasterGridCrop(t *testing.T) {
+	inverse, err := invertGeoTransform(utmGeoTransform)
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := &rasterGrid{inverse: inverse, width: 10980, height: 10980}
+	g.crop(zoneWindow{100, 200, 150, 260, 10980, 10980})
+	if g.width != 50 || g.height != 60 {
+		t.Errorf("cropped grid has size %dx%d, want 50x60", g.width, g.height)
+	}
+
+	// Upper left corner of pixel 100/200 is the origin of the window
+	x, y := applyGeoTransform(g.inverse, 600000+100*10, 5700000-200*10)
+	if x != 0 || y != 0 {
+		t.Errorf("origin of window = %v/%v, want 0/0", x, y)
+	}
+}
@@ -0,0 +1,262 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/paulsmith/gogeos/geos"
+	"github.com/segmentio/ksuid"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Request Schema of /zonalstats/timeseries
+type zonalTimeseriesRequest struct {
+	// Band name, band math expression or name of a built-in index
+	Band string `json:"band"`
+	// GeoJSON Polygon, MultiPolygon or Feature in WGS84
+	Geometry json.RawMessage `json:"geometry"`
+	// Date range as dates like 2018-05-01 or RFC 3339 timestamps
+	From string `json:"from"`
+	To   string `json:"to"`
+	// Comma separated scene classes to mask, defaults to clouds and cloud shadows.
+	// L1C products only mask the cloud classes of the mask.
+	Mask string `json:"mask"`
+	// Optional filters like in /search
+	Level    string `json:"level"`
+	MaxCloud string `json:"maxcloud"`
+}
+
+// Result Schema of /zonalstats/timeseries jobs
+type zonalTimeseriesResult struct {
+	Band         string                       `json:"band"`
+	Observations []zonalTimeseriesObservation `json:"observations"`
+	Skipped      []zonalTimeseriesSkipped     `json:"skipped"`
+}
+
+// zonalTimeseriesSkipped is a product without observation and the reason why
+type zonalTimeseriesSkipped struct {
+	Dataset string `json:"dataset"`
+	Reason  string `json:"reason"`
+}
+
+type zonalTimeseriesObservation struct {
+	Date       time.Time `json:"date"`
+	Dataset    string    `json:"dataset"`
+	Level      string    `json:"level"`
+	CloudCover *float64  `json:"cloudCover"`
+	// Scene classes masked in this product and the share of pixels inside the polygon removed by them
+	Mask           string  `json:"mask"`
+	MaskedFraction float64 `json:"maskedFraction"`
+	zonalStatistics
+}
+
+// ZonalTimeseriesHandler queues a job computing statistics of a band or index inside a polygon for every intersecting product
+func ZonalTimeseriesHandler(w http.ResponseWriter, r *http.Request) {
+	defer Timetrack(time.Now(), "Zonal Timeseries ")
+
+	var request zonalTimeseriesRequest
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		w.WriteHeader(400)
+		w.Write([]byte("Unable to parse request body: " + err.Error()))
+		return
+	}
+
+	// Log request if verbose is set
+	if Verbose {
+		fmt.Println("Request to /zonalstats/timeseries for band " + request.Band + " from " + request.From + " to " + request.To)
+	}
+
+	if request.Band == "" || request.Geometry == nil {
+		w.WriteHeader(400)
+		w.Write([]byte("Fields 'band' and 'geometry' are required"))
+		return
+	}
+	expr, err := parseExpression(request.Band)
+	if err != nil {
+		w.WriteHeader(400)
+		w.Write([]byte("Invalid band or expression: " + err.Error()))
+		return
+	}
+	if request.Mask == "" {
+		request.Mask = strings.Join(cloudySceneClasses, ",")
+	}
+	_, err = parseMaskClasses(request.Mask)
+	if err != nil {
+		w.WriteHeader(400)
+		w.Write([]byte(err.Error()))
+		return
+	}
+	from, to, err := parseDayRange(request.From, request.To)
+	if err != nil {
+		w.WriteHeader(400)
+		w.Write([]byte(err.Error()))
+		return
+	}
+	features, err := parseFeatures(request.Geometry)
+	if err != nil {
+		w.WriteHeader(400)
+		w.Write([]byte(err.Error()))
+		return
+	}
+	if len(features) != 1 {
+		w.WriteHeader(400)
+		w.Write([]byte("Exactly one polygon is required"))
+		return
+	}
+	zones, err := featureGeometries(features)
+	if err != nil {
+		w.WriteHeader(400)
+		w.Write([]byte(err.Error()))
+		return
+	}
+	_, err = polygonsOf(zones[0])
+	if err != nil {
+		w.WriteHeader(400)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	// Find all products intersecting the polygon
+	products, err := intersectingProducts(zones[0], from, to, url.Values{
+		"level":    {request.Level},
+		"maxcloud": {request.MaxCloud},
+	})
+	if err != nil {
+		w.WriteHeader(400)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	// Compute statistics in background
+	id, err := ksuid.NewRandom()
+	if err != nil {
+		w.WriteHeader(500)
+		w.Write([]byte("Unable to create job id: " + err.Error()))
+		return
+	}
+	_, err = Jobs.Enqueue(id.String(), func(job *Job) error {
+		result := zonalTimeseries(products, zones[0], expr, request.Mask, func(percent int) {
+			Jobs.SetProgress(job, percent)
+		})
+		result.Band = request.Band
+		Jobs.SetResult(job, result)
+
+		// Fail jobs without any observation, the result still lists why products were skipped
+		if len(products) == 0 {
+			return errors.New("No products intersect the polygon within the date range")
+		}
+		if len(result.Observations) == 0 {
+			return errors.New("None of the " + strconv.Itoa(len(products)) + " intersecting products could be read")
+		}
+		return nil
+	})
+	if err != nil {
+		w.WriteHeader(503)
+		w.Write([]byte("Unable to queue job: " + err.Error()))
+		return
+	}
+
+	// 202 Response with job id
+	w.WriteHeader(202)
+	w.Write([]byte(id.String()))
+}
+
+// intersectingProducts returns all products intersecting area sensed within from and to, filtered by q like /search, sorted by date
+func intersectingProducts(area *geos.Geometry, from, to time.Time, q url.Values) ([]*Product, error) {
+	datasets, err := Index.All()
+	if err != nil {
+		return nil, err
+	}
+	err = propertiesFilter(datasets, q)
+	if err != nil {
+		return nil, err
+	}
+	spatial, err := newSpatialFilter(area, "intersects", "")
+	if err != nil {
+		return nil, err
+	}
+	sensingTimeFilter(datasets, from, to)
+	err = metaDataFilter(datasets, "", "", spatial, false)
+	if err != nil {
+		return nil, errors.New("Unable to filter by metadata: " + err.Error())
+	}
+
+	var products []*Product
+	for _, dataset := range datasets {
+		if dataset != nil {
+			products = append(products, dataset)
+		}
+	}
+	sort.Slice(products, func(i, j int) bool { return products[i].SensingTime.Before(products[j].SensingTime) })
+	return products, nil
+}
+
+// zonalTimeseries computes statistics of expr inside zone for every product.
+// Products that cannot be read are skipped and listed with the reason in the result.
+func zonalTimeseries(products []*Product, zone *geos.Geometry, expr *expression, mask string, progress func(percent int)) zonalTimeseriesResult {
+	result := zonalTimeseriesResult{
+		Observations: []zonalTimeseriesObservation{},
+		Skipped:      []zonalTimeseriesSkipped{},
+	}
+	for i, p := range products {
+		progress(i * 100 / len(products))
+
+		// L1C products can only mask clouds but not their shadows
+		productMask := mask
+		var err error
+		if p.Level == "L1C" {
+			productMask, err = l1cMaskClasses(mask)
+		}
+
+		var observation zonalTimeseriesObservation
+		if err == nil {
+			observation, err = zonalObservation(p, zone, expr, productMask)
+		}
+		if err != nil {
+			// Skip products missing bands or not covering the polygon despite their footprint
+			if Verbose {
+				fmt.Println("Skipping " + p.Name + ": " + err.Error())
+			}
+			result.Skipped = append(result.Skipped, zonalTimeseriesSkipped{Dataset: p.Name, Reason: err.Error()})
+			continue
+		}
+		result.Observations = append(result.Observations, observation)
+	}
+	return result
+}
+
+// zonalObservation computes statistics of expr inside zone for a single product
+func zonalObservation(p *Product, zone *geos.Geometry, expr *expression, mask string) (zonalTimeseriesObservation, error) {
+	observation := zonalTimeseriesObservation{
+		Date:       p.SensingTime,
+		Dataset:    p.Name,
+		Level:      p.Level,
+		CloudCover: p.CloudCover,
+		Mask:       mask,
+	}
+	err := checkBands(p.Name, expr)
+	if err != nil {
+		return observation, err
+	}
+	data, masked, grid, err := readZoneData(p.Name, expr, mask, []*geos.Geometry{zone})
+	if err != nil {
+		return observation, err
+	}
+	defer grid.Close()
+	pixels, err := grid.polygonPixels(zone)
+	if err != nil {
+		return observation, err
+	}
+	if len(pixels) == 0 {
+		return observation, errors.New("Polygon does not cover any pixel of the Dataset")
+	}
+	observation.zonalStatistics = zonalStatisticsOf(data, masked, pixels)
+	observation.MaskedFraction = float64(observation.Masked) / float64(observation.Pixels)
+	return observation, nil
+}